@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// bookmark marks a page by its real PDF page number, so it stays valid when
+// content detection changes which pages are in textPages.
+type bookmark struct {
+	Page    int       `json:"page"` // 0-indexed PDF page
+	Label   string    `json:"label,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// bookmarkAt returns the index of the bookmark on the given PDF page, or -1.
+func (d *DocumentViewer) bookmarkAt(pdfPage int) int {
+	for i, bm := range d.bookmarks {
+		if bm.Page == pdfPage {
+			return i
+		}
+	}
+	return -1
+}
+
+// addBookmark prompts for an optional label and bookmarks the current page.
+// An existing bookmark on the page is relabelled instead.
+func (d *DocumentViewer) addBookmark(inputChan <-chan keyEvent) {
+	pdfPage := d.textPages[d.currentPage]
+	existing := d.bookmarkAt(pdfPage)
+	initial := ""
+	if existing >= 0 {
+		initial = d.bookmarks[existing].Label
+	}
+
+	label, ok := d.promptLine(inputChan, fmt.Sprintf("Bookmark page %s, label (optional): ", d.pageLabel(pdfPage)), initial)
+	if !ok {
+		return
+	}
+	label = strings.TrimSpace(label)
+
+	if existing >= 0 {
+		d.bookmarks[existing].Label = label
+	} else {
+		d.bookmarks = append(d.bookmarks, bookmark{Page: pdfPage, Label: label, Created: time.Now()})
+		sort.Slice(d.bookmarks, func(i, j int) bool {
+			return d.bookmarks[i].Page < d.bookmarks[j].Page
+		})
+	}
+	d.saveState()
+}
+
+// showBookmarks lists bookmarks in an overlay for jumping, relabelling and deleting.
+func (d *DocumentViewer) showBookmarks(inputChan <-chan keyEvent) {
+	selected := 0
+	if i := d.bookmarkAt(d.textPages[d.currentPage]); i >= 0 {
+		selected = i
+	}
+	offset := 0
+
+	for {
+		termWidth, termHeight := d.getTerminalSize()
+		// header (3) + footer (2)
+		listHeight := termHeight - 5
+		if listHeight < 1 {
+			listHeight = 1
+		}
+		if selected >= len(d.bookmarks) {
+			selected = len(d.bookmarks) - 1
+		}
+		if selected < 0 {
+			selected = 0
+		}
+		if selected < offset {
+			offset = selected
+		} else if selected >= offset+listHeight {
+			offset = selected - listHeight + 1
+		}
+
+		d.drawBookmarks(selected, offset, termWidth, listHeight)
+
+		ch := (<-inputChan).char()
+		if len(d.bookmarks) == 0 {
+			return
+		}
+		switch ch {
+		case 'q', '\'', 27: // close
+			return
+		case 'j':
+			if selected < len(d.bookmarks)-1 {
+				selected++
+			}
+		case 'k':
+			if selected > 0 {
+				selected--
+			}
+		case 13, 10: // Enter: jump
+			d.recordJump()
+			d.jumpToPage(d.bookmarks[selected].Page + 1)
+			return
+		case 'd', 'x': // delete
+			d.bookmarks = append(d.bookmarks[:selected], d.bookmarks[selected+1:]...)
+			d.saveState()
+		case 'r': // relabel
+			bm := &d.bookmarks[selected]
+			label, ok := d.promptLine(inputChan, fmt.Sprintf("Label for page %s: ", d.pageLabel(bm.Page)), bm.Label)
+			if ok {
+				bm.Label = strings.TrimSpace(label)
+				d.saveState()
+			}
+		}
+	}
+}
+
+func (d *DocumentViewer) drawBookmarks(selected, offset, termWidth, listHeight int) {
+	d.clearScreen()
+	p := func(s string) { fmt.Print(s + "\r\n") }
+
+	p(strings.Repeat("=", termWidth))
+	p(fmt.Sprintf("Bookmarks (%d)", len(d.bookmarks)))
+	p(strings.Repeat("=", termWidth))
+
+	if len(d.bookmarks) == 0 {
+		p("")
+		p("  No bookmarks yet. Press m on a page to add one.")
+		p("")
+		fmt.Print("\033[2m  Press any key to return...\033[0m")
+		return
+	}
+
+	current := d.textPages[d.currentPage]
+	end := offset + listHeight
+	if end > len(d.bookmarks) {
+		end = len(d.bookmarks)
+	}
+	for i := offset; i < end; i++ {
+		bm := d.bookmarks[i]
+		label := bm.Label
+		if label == "" {
+			label = "(no label)"
+		}
+		line := fmt.Sprintf("  Page %-8s %s", d.pageLabel(bm.Page), label)
+		if runes := []rune(line); len(runes) > termWidth {
+			line = string(runes[:termWidth-1]) + "…"
+		}
+
+		switch {
+		case i == selected:
+			fmt.Print("\033[7m" + line + "\033[0m\r\n") // reverse video
+		case bm.Page == current:
+			fmt.Print("\033[1;33m" + line + "\033[0m\r\n") // current page
+		default:
+			p(line)
+		}
+	}
+
+	fmt.Print("\r\n")
+	fmt.Print("\033[2m  j/k: Move  Enter: Jump  r: Relabel  d: Delete  q/Esc: Close\033[0m")
+}
+
+// bookmarkIndicator returns the status bar tag for a bookmarked current page.
+func (d *DocumentViewer) bookmarkIndicator() string {
+	i := d.bookmarkAt(d.textPages[d.currentPage])
+	if i < 0 {
+		return ""
+	}
+	if d.bookmarks[i].Label != "" {
+		return fmt.Sprintf(" [bm:%s]", d.bookmarks[i].Label)
+	}
+	return " [bm]"
+}
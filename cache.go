@@ -0,0 +1,232 @@
+package main
+
+import (
+	"container/list"
+	"errors"
+	"image"
+	"sync"
+)
+
+// renderCacheBytes bounds the memory held by cached page images.
+const renderCacheBytes = 192 << 20
+
+// errRenderPending is returned by pageImage when the page was handed to the
+// render worker; the page is redrawn once it is ready.
+var errRenderPending = errors.New("page is being rendered")
+
+// renderKey identifies a rendered page image.
+type renderKey struct {
+	page     int     // 0-indexed PDF page
+	dpi      float64 // render resolution
+	darkMode string  // dark mode applied to the image
+	fitMode  string  // fit mode the resolution was computed for
+	marks    string  // search occurrences painted on the image (see searchMarks); "" for none
+}
+
+type cacheEntry struct {
+	key  renderKey
+	img  image.Image
+	size int // bytes
+}
+
+// renderCache is an LRU cache of rendered page images (after dark mode,
+// before link hints and cropping; with search occurrences painted on, under
+// keys of their own), shared by the main loop, the render worker and the
+// search worker. Cached images are never modified. It also
+// keeps the text of pages, which is small next to their images.
+type renderCache struct {
+	mu       sync.Mutex
+	maxBytes int
+	bytes    int
+	entries  map[renderKey]*list.Element
+	order    *list.List // front = most recently used
+	gen      int        // bumped when the document changes; stale renders are dropped
+	failed   map[renderKey]error
+	visual   map[int]bool   // pageHasVisualContent results by PDF page
+	text     map[int]string // extracted text by PDF page
+}
+
+func newRenderCache(maxBytes int) *renderCache {
+	return &renderCache{
+		maxBytes: maxBytes,
+		entries:  make(map[renderKey]*list.Element),
+		order:    list.New(),
+		failed:   make(map[renderKey]error),
+		visual:   make(map[int]bool),
+		text:     make(map[int]string),
+	}
+}
+
+func (c *renderCache) get(key renderKey) (image.Image, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*cacheEntry).img, true
+}
+
+func (c *renderCache) has(key renderKey) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.entries[key]
+	return ok
+}
+
+// put stores an image rendered for document generation gen. Renders of an
+// older generation (the file was reloaded meanwhile) are discarded.
+func (c *renderCache) put(key renderKey, img image.Image, gen int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen != c.gen {
+		return
+	}
+	if el, ok := c.entries[key]; ok {
+		c.order.MoveToFront(el)
+		return
+	}
+	b := img.Bounds()
+	e := &cacheEntry{key: key, img: img, size: b.Dx() * b.Dy() * 4}
+	c.entries[key] = c.order.PushFront(e)
+	c.bytes += e.size
+
+	// Evict least recently used images, always keeping the newest one
+	for c.bytes > c.maxBytes && c.order.Len() > 1 {
+		oldest := c.order.Back()
+		old := oldest.Value.(*cacheEntry)
+		c.order.Remove(oldest)
+		delete(c.entries, old.key)
+		c.bytes -= old.size
+	}
+}
+
+// putMarked stores a page image with search occurrences painted on,
+// dropping the ones painted for earlier states of the search.
+func (c *renderCache) putMarked(key renderKey, img image.Image, gen int) {
+	c.mu.Lock()
+	for el := c.order.Front(); el != nil; {
+		next := el.Next()
+		old := el.Value.(*cacheEntry)
+		if old.key.marks != "" && old.key.marks != key.marks && old.key.page == key.page {
+			c.order.Remove(el)
+			delete(c.entries, old.key)
+			c.bytes -= old.size
+		}
+		el = next
+	}
+	c.mu.Unlock()
+	c.put(key, img, gen)
+}
+
+// clear drops all images and starts a new document generation. Call it
+// whenever page contents change (reload, HTML relayout).
+func (c *renderCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[renderKey]*list.Element)
+	c.order.Init()
+	c.bytes = 0
+	c.failed = make(map[renderKey]error)
+	c.visual = make(map[int]bool)
+	c.text = make(map[int]string)
+	c.gen++
+}
+
+// putFailed remembers that a page cannot be rendered, so it is not retried
+// on every redraw.
+func (c *renderCache) putFailed(key renderKey, err error, gen int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen == c.gen {
+		c.failed[key] = err
+	}
+}
+
+func (c *renderCache) failure(key renderKey) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.failed[key]
+}
+
+func (c *renderCache) visualContent(page int) (visual, known bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	visual, known = c.visual[page]
+	return visual, known
+}
+
+func (c *renderCache) putVisualContent(page int, visual bool, gen int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen == c.gen {
+		c.visual[page] = visual
+	}
+}
+
+func (c *renderCache) pageText(page int) (text string, known bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	text, known = c.text[page]
+	return text, known
+}
+
+func (c *renderCache) putPageText(page int, text string, gen int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen == c.gen {
+		c.text[page] = text
+	}
+}
+
+func (c *renderCache) generation() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gen
+}
+
+// pageKey returns the cache key of a page rendered at dpi with the current
+// view settings.
+func (d *DocumentViewer) pageKey(pageNum int, dpi float64) renderKey {
+	return renderKey{page: pageNum, dpi: dpi, darkMode: d.darkMode, fitMode: d.fitMode}
+}
+
+// pageImage returns a page rendered at dpi with dark mode applied, from the
+// cache when possible. During an asynchronous redraw a missing page is queued
+// for the render worker and errRenderPending is returned.
+func (d *DocumentViewer) pageImage(pageNum int, dpi float64) (image.Image, error) {
+	key := d.pageKey(pageNum, dpi)
+	if img, ok := d.cache.get(key); ok {
+		return img, nil
+	}
+	if err := d.cache.failure(key); err != nil {
+		return nil, err
+	}
+	if d.asyncRender {
+		d.queueRender(renderJob{key: key})
+		return nil, errRenderPending
+	}
+	gen := d.cache.generation()
+	img, err := d.doc.ImageDPI(pageNum, dpi)
+	if err != nil {
+		return nil, err
+	}
+	finalImg := applyDarkMode(img, d.darkMode)
+	d.cache.put(key, finalImg, gen)
+	return finalImg, nil
+}
+
+// pageText returns the text of a page, from the cache when possible.
+func (d *DocumentViewer) pageText(pageNum int) (string, error) {
+	if text, ok := d.cache.pageText(pageNum); ok {
+		return text, nil
+	}
+	gen := d.cache.generation()
+	text, err := d.doc.Text(pageNum)
+	if err != nil {
+		return "", err
+	}
+	d.cache.putPageText(pageNum, text, gen)
+	return text, nil
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"strings"
+)
+
+// Terminals without a graphics protocol get page images drawn with text:
+// upper half blocks (▀) whose foreground and background colours are the top
+// and bottom half of the cell, in truecolor or the xterm 256-colour palette,
+// or braille dots (2x4 per cell) for monochrome output. While this is in use
+// each cell counts as cellModeWidth x cellModeHeight pixels, so pages are
+// rendered for it by the usual fit/zoom code and scaled down when printed.
+
+const (
+	cellModeWidth  = 8
+	cellModeHeight = 16
+)
+
+// cellModes are the values accepted by --cells.
+var cellModes = []string{"truecolor", "256", "braille"}
+
+// defaultCellMode picks half blocks in the richest colour mode the terminal
+// announces.
+func defaultCellMode() string {
+	switch os.Getenv("COLORTERM") {
+	case "truecolor", "24bit":
+		return "truecolor"
+	}
+	return "256"
+}
+
+// cellRenderMode returns how page images are drawn with text cells, or ""
+// when the terminal's graphics protocol is used.
+func (d *DocumentViewer) cellRenderMode() string {
+	if d.cellMode != "" {
+		return d.cellMode
+	}
+	if d.graphicsProtocol(d.detectTerminalType()) != "" {
+		return ""
+	}
+	return defaultCellMode()
+}
+
+// printCells draws img at the cursor with text cells, each row starting
+// offset columns from the left edge.
+func printCells(img image.Image, mode string, offset int, darkMode string) error {
+	w := bufio.NewWriterSize(os.Stdout, 64<<10)
+	src := rgbaImage(img)
+	b := src.Bounds()
+	cols := (b.Dx() + cellModeWidth - 1) / cellModeWidth
+	rows := (b.Dy() + cellModeHeight - 1) / cellModeHeight
+
+	for row := 0; row < rows; row++ {
+		if row > 0 {
+			fmt.Fprint(w, "\033[1E") // next line, first column
+			if offset > 0 {
+				fmt.Fprintf(w, "\033[%dC", offset)
+			}
+		}
+		y := row * cellModeHeight
+		if mode == "braille" {
+			writeBrailleRow(w, src, y, cols, darkMode)
+		} else {
+			writeHalfBlockRow(w, src, y, cols, mode == "256")
+		}
+		fmt.Fprint(w, "\033[0m")
+	}
+	return w.Flush()
+}
+
+// writeHalfBlockRow writes one row of half-block cells starting at pixel
+// row y.
+func writeHalfBlockRow(w io.Writer, img *image.RGBA, y, cols int, palette bool) {
+	var sb strings.Builder
+	lastFg, lastBg := "", ""
+	half := cellModeHeight / 2
+	for col := 0; col < cols; col++ {
+		x := col * cellModeWidth
+		top, _ := averageColor(img, image.Rect(x, y, x+cellModeWidth, y+half))
+		fg, bg := sgrColor(top, palette, 38), "49" // default background below the image
+		if bottom, ok := averageColor(img, image.Rect(x, y+half, x+cellModeWidth, y+cellModeHeight)); ok {
+			bg = sgrColor(bottom, palette, 48)
+		}
+		if fg != lastFg || bg != lastBg {
+			sb.WriteString("\033[" + fg + ";" + bg + "m")
+			lastFg, lastBg = fg, bg
+		}
+		sb.WriteString("▀")
+	}
+	io.WriteString(w, sb.String())
+}
+
+// writeBrailleRow writes one row of braille cells starting at pixel row y.
+// Each dot covers half the width and a quarter of the height of the cell and
+// is set where the page has ink: darker than the paper, or lighter in dark
+// mode.
+func writeBrailleRow(w io.Writer, img *image.RGBA, y, cols int, darkMode string) {
+	// Bit of each dot, by dot row and column
+	dotBits := [4][2]rune{{0x01, 0x08}, {0x02, 0x10}, {0x04, 0x20}, {0x40, 0x80}}
+	dotW, dotH := cellModeWidth/2, cellModeHeight/4
+
+	var sb strings.Builder
+	if darkMode != "" {
+		sb.WriteString("\033[97;40m")
+	} else {
+		sb.WriteString("\033[30;107m")
+	}
+	for col := 0; col < cols; col++ {
+		x := col * cellModeWidth
+		cell := rune(0x2800)
+		for dy := 0; dy < 4; dy++ {
+			for dx := 0; dx < 2; dx++ {
+				r := image.Rect(x+dx*dotW, y+dy*dotH, x+(dx+1)*dotW, y+(dy+1)*dotH)
+				c, ok := averageColor(img, r)
+				if !ok {
+					continue
+				}
+				lum := luminance(c)
+				if darkMode != "" {
+					lum = 255 - lum
+				}
+				// Text is only a pixel or two thick at this resolution, so
+				// any clear darkening counts as ink
+				if lum < 224 {
+					cell |= dotBits[dy][dx]
+				}
+			}
+		}
+		sb.WriteRune(cell)
+	}
+	io.WriteString(w, sb.String())
+}
+
+// averageColor returns the mean colour of the part of img inside r (relative
+// to the image origin). Cells on the right and bottom edge may be cut off;
+// only the pixels inside the image count, and ok is false if there are none.
+func averageColor(img *image.RGBA, r image.Rectangle) (c [3]int, ok bool) {
+	r = r.Add(img.Bounds().Min).Intersect(img.Bounds())
+	var sum [3]int
+	n := r.Dx() * r.Dy()
+	if n == 0 {
+		return sum, false
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		i := img.PixOffset(r.Min.X, y)
+		for x := r.Min.X; x < r.Max.X; x++ {
+			sum[0] += int(img.Pix[i])
+			sum[1] += int(img.Pix[i+1])
+			sum[2] += int(img.Pix[i+2])
+			i += 4
+		}
+	}
+	for i := range sum {
+		sum[i] /= n
+	}
+	return sum, true
+}
+
+func luminance(c [3]int) int {
+	return (299*c[0] + 587*c[1] + 114*c[2]) / 1000
+}
+
+// sgrColor returns the SGR parameters setting the foreground (base 38) or
+// background (base 48) colour to c, in truecolor or as the nearest colour
+// of the xterm 256-colour palette.
+func sgrColor(c [3]int, palette bool, base int) string {
+	if !palette {
+		return fmt.Sprintf("%d;2;%d;%d;%d", base, c[0], c[1], c[2])
+	}
+	return fmt.Sprintf("%d;5;%d", base, xterm256(c))
+}
+
+// xterm256 returns the index of the closest colour in the 6x6x6 cube or the
+// grey ramp of the xterm 256-colour palette.
+func xterm256(c [3]int) int {
+	levels := [6]int{0, 95, 135, 175, 215, 255}
+	nearestLevel := func(v int) int {
+		best := 0
+		for i, l := range levels {
+			if abs(v-l) < abs(v-levels[best]) {
+				best = i
+			}
+		}
+		return best
+	}
+	ri, gi, bi := nearestLevel(c[0]), nearestLevel(c[1]), nearestLevel(c[2])
+	cube := [3]int{levels[ri], levels[gi], levels[bi]}
+
+	// Grey ramp: 232..255 are 8, 18, ..., 238
+	avg := (c[0] + c[1] + c[2]) / 3
+	step := min(max((avg-8+5)/10, 0), 23)
+	grey := 8 + 10*step
+
+	if colorDistance(c, [3]int{grey, grey, grey}) < colorDistance(c, cube) {
+		return 232 + step
+	}
+	return 16 + 36*ri + 6*gi + bi
+}
+
+func colorDistance(a, b [3]int) int {
+	dr, dg, db := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return dr*dr + dg*dg + db*db
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,458 @@
+package main
+
+import (
+	"fmt"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The ':' command line sets view options to exact values (":zoom 135",
+// ":set dark smart") and runs commands that keys cannot express, like
+// ":open <path>". Commands change the viewer through the same methods as
+// the keys, and may return an action code like handleInput does. Command
+// names can be abbreviated to any unique prefix.
+
+// maxCommandHistory bounds the command line history.
+const maxCommandHistory = 100
+
+// commandHistory holds the command lines run this session, oldest first.
+// It is kept across documents opened from the picker or with :open.
+var commandHistory []string
+
+// viewOption is a setting that :set reads and changes.
+type viewOption struct {
+	name   string
+	values []string // accepted values, for completion; nil for numbers
+	get    func(d *DocumentViewer) string
+	set    func(d *DocumentViewer, value string) error
+}
+
+var viewOptions = []viewOption{
+	{
+		name:   "fit",
+		values: []string{"height", "width", "auto"},
+		get:    func(d *DocumentViewer) string { return d.fitMode },
+		set: func(d *DocumentViewer, value string) error {
+			d.fitMode = value
+			return nil
+		},
+	},
+	{
+		name:   "view",
+		values: []string{"auto", "text", "image"},
+		get: func(d *DocumentViewer) string {
+			if d.forceMode == "" {
+				return "auto"
+			}
+			return d.forceMode
+		},
+		set: func(d *DocumentViewer, value string) error {
+			if value == "auto" {
+				value = ""
+			}
+			d.forceMode = value
+			return nil
+		},
+	},
+	{
+		name:   "dark",
+		values: []string{"off", "smart", "invert"},
+		get: func(d *DocumentViewer) string {
+			if d.darkMode == "" {
+				return "off"
+			}
+			return d.darkMode
+		},
+		set: func(d *DocumentViewer, value string) error {
+			if value == "off" {
+				value = ""
+			}
+			d.darkMode = value
+			return nil
+		},
+	},
+	{
+		name:   "dual",
+		values: []string{"off", "vertical", "horizontal"},
+		get: func(d *DocumentViewer) string {
+			if d.dualPageMode == "" {
+				return "off"
+			}
+			return d.dualPageMode
+		},
+		set: func(d *DocumentViewer, value string) error {
+			if value == "off" {
+				value = ""
+			}
+			d.setDualPageMode(value)
+			return nil
+		},
+	},
+	{
+		name:   "continuous",
+		values: []string{"on", "off"},
+		get: func(d *DocumentViewer) string {
+			if d.continuous {
+				return "on"
+			}
+			return "off"
+		},
+		set: func(d *DocumentViewer, value string) error {
+			d.setContinuous(value == "on")
+			return nil
+		},
+	},
+	{
+		name:   "wholeword",
+		values: []string{"on", "off"},
+		get: func(d *DocumentViewer) string {
+			if d.searchWholeWord {
+				return "on"
+			}
+			return "off"
+		},
+		set: func(d *DocumentViewer, value string) error {
+			d.searchWholeWord = value == "on"
+			if d.searchRe != nil {
+				d.beginSearch(false)
+			}
+			return nil
+		},
+	},
+	{
+		name:   "diacritics",
+		values: []string{"ignore", "match"},
+		get: func(d *DocumentViewer) string {
+			if d.searchMatchDiacritics {
+				return "match"
+			}
+			return "ignore"
+		},
+		set: func(d *DocumentViewer, value string) error {
+			d.searchMatchDiacritics = value == "match"
+			if d.searchRe == nil {
+				return nil
+			}
+			// The query is folded like the text it is matched in
+			re, err := compileSearch(d.searchQuery, d.searchFold(d.searchQuery))
+			if err != nil {
+				return err
+			}
+			d.searchRe = re
+			d.beginSearch(false)
+			return nil
+		},
+	},
+	{
+		name: "zoom",
+		get:  func(d *DocumentViewer) string { return strconv.Itoa(d.zoomPercent()) + "%" },
+		set: func(d *DocumentViewer, value string) error {
+			pct, err := strconv.Atoi(strings.TrimSuffix(value, "%"))
+			if err != nil || pct < 10 || pct > 200 {
+				return fmt.Errorf("zoom must be a percentage from 10 to 200")
+			}
+			d.setZoomPercent(pct)
+			return nil
+		},
+	},
+}
+
+// exCommand is a command of the ':' command line.
+type exCommand struct {
+	name     string
+	complete func(d *DocumentViewer, arg string) []string // candidates for the argument, or nil
+	run      func(d *DocumentViewer, arg string) (int, error)
+}
+
+// exCommands is filled in by init, which adds a command for every view
+// option.
+var exCommands []exCommand
+
+func init() {
+	exCommands = []exCommand{
+		{name: "set", complete: completeSet, run: (*DocumentViewer).cmdSet},
+		{name: "page", run: (*DocumentViewer).cmdPage},
+		{name: "open", complete: completeDocumentPath, run: (*DocumentViewer).cmdOpen},
+		{name: "export", run: (*DocumentViewer).cmdExport},
+		{name: "help", run: func(d *DocumentViewer, arg string) (int, error) { return -3, nil }},
+		{name: "quit", run: func(d *DocumentViewer, arg string) (int, error) { return 1, nil }},
+	}
+	// Every option is a command of its own: ":zoom 135" is ":set zoom 135"
+	for _, opt := range viewOptions {
+		values := opt.values
+		exCommands = append(exCommands, exCommand{
+			name: opt.name,
+			complete: func(d *DocumentViewer, arg string) []string {
+				return matchingPrefix(values, arg)
+			},
+			run: func(d *DocumentViewer, arg string) (int, error) {
+				return d.cmdSet(opt.name + " " + arg)
+			},
+		})
+	}
+}
+
+// commandLine reads a command on the bottom row and runs it. It returns
+// the action code of the command, or 0.
+func (d *DocumentViewer) commandLine(inputChan <-chan keyEvent) int {
+	line, ok := d.readLine(inputChan, ":", "", commandHistory, d.completeCommand)
+	line = strings.TrimSpace(line)
+	if !ok || line == "" {
+		return 0
+	}
+	commandHistory = slices.DeleteFunc(commandHistory, func(s string) bool { return s == line })
+	commandHistory = append(commandHistory, line)
+	if len(commandHistory) > maxCommandHistory {
+		commandHistory = commandHistory[len(commandHistory)-maxCommandHistory:]
+	}
+
+	action, err := d.runCommand(line)
+	if err != nil {
+		d.statusMessage = err.Error()
+	}
+	return action
+}
+
+// runCommand runs one command line.
+func (d *DocumentViewer) runCommand(line string) (int, error) {
+	name, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+	cmd, err := lookupCommand(name)
+	if err != nil {
+		return 0, err
+	}
+	return cmd.run(d, strings.TrimSpace(arg))
+}
+
+// lookupCommand finds a command by its name or a unique prefix of it.
+func lookupCommand(name string) (*exCommand, error) {
+	var found *exCommand
+	for i := range exCommands {
+		cmd := &exCommands[i]
+		if cmd.name == name {
+			return cmd, nil
+		}
+		if name != "" && strings.HasPrefix(cmd.name, name) {
+			if found != nil {
+				return nil, fmt.Errorf("ambiguous command: %s", name)
+			}
+			found = cmd
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("unknown command: %s", name)
+	}
+	return found, nil
+}
+
+// completeCommand returns the command lines a partial line completes to.
+func (d *DocumentViewer) completeCommand(line string) []string {
+	name, arg, hasArg := strings.Cut(line, " ")
+	if !hasArg {
+		var names []string
+		for _, cmd := range exCommands {
+			names = append(names, cmd.name)
+		}
+		sort.Strings(names)
+		return matchingPrefix(names, name)
+	}
+	cmd, err := lookupCommand(name)
+	if err != nil || cmd.complete == nil {
+		return nil
+	}
+	var lines []string
+	for _, c := range cmd.complete(d, arg) {
+		lines = append(lines, cmd.name+" "+c)
+	}
+	return lines
+}
+
+// matchingPrefix returns the strings of ss that start with prefix.
+func matchingPrefix(ss []string, prefix string) []string {
+	var matches []string
+	for _, s := range ss {
+		if strings.HasPrefix(s, prefix) {
+			matches = append(matches, s)
+		}
+	}
+	return matches
+}
+
+// lookupOption finds a view option by name.
+func lookupOption(name string) (*viewOption, error) {
+	for i := range viewOptions {
+		if viewOptions[i].name == name {
+			return &viewOptions[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unknown option: %s", name)
+}
+
+// cmdSet sets an option (":set dark smart" or ":set dark=smart"), shows its
+// value (":set dark") or shows all of them (":set").
+func (d *DocumentViewer) cmdSet(arg string) (int, error) {
+	if arg == "" {
+		var values []string
+		for _, opt := range viewOptions {
+			values = append(values, opt.name+"="+opt.get(d))
+		}
+		d.statusMessage = strings.Join(values, "  ")
+		return 0, nil
+	}
+	name, value, hasValue := strings.Cut(arg, "=")
+	if !hasValue {
+		name, value, hasValue = strings.Cut(arg, " ")
+	}
+	name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+	opt, err := lookupOption(name)
+	if err != nil {
+		return 0, err
+	}
+	if !hasValue || value == "" {
+		d.statusMessage = opt.name + "=" + opt.get(d)
+		return 0, nil
+	}
+	if opt.values != nil && !slices.Contains(opt.values, value) {
+		return 0, fmt.Errorf("%s must be one of: %s", opt.name, strings.Join(opt.values, ", "))
+	}
+	return 0, opt.set(d, value)
+}
+
+// completeSet completes option names, then their values.
+func completeSet(d *DocumentViewer, arg string) []string {
+	name, value, hasValue := strings.Cut(arg, " ")
+	if !hasValue {
+		var names []string
+		for _, opt := range viewOptions {
+			names = append(names, opt.name)
+		}
+		return matchingPrefix(names, name)
+	}
+	opt, err := lookupOption(name)
+	if err != nil {
+		return nil
+	}
+	var lines []string
+	for _, v := range matchingPrefix(opt.values, value) {
+		lines = append(lines, name+" "+v)
+	}
+	return lines
+}
+
+// cmdPage goes to a page, given like in the go-to-page prompt.
+func (d *DocumentViewer) cmdPage(arg string) (int, error) {
+	pdfPage, found := d.resolvePageInput(arg)
+	if !found {
+		return 0, fmt.Errorf("no such page: %s", arg)
+	}
+	d.recordJump()
+	d.jumpToPage(pdfPage + 1)
+	return 0, nil
+}
+
+// cmdOpen closes the document and opens another one in its place.
+func (d *DocumentViewer) cmdOpen(arg string) (int, error) {
+	if arg == "" {
+		return 0, fmt.Errorf("usage: open <path>")
+	}
+	path := expandHome(arg)
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return 0, fmt.Errorf("no such file: %s", arg)
+	} else if err != nil {
+		return 0, err
+	}
+	if info.IsDir() || !isDocumentPath(path) {
+		return 0, fmt.Errorf("not a supported document: %s", arg)
+	}
+	d.openPath = path
+	d.wantBack = true
+	return 1, nil
+}
+
+// completeDocumentPath completes directories and supported documents.
+func completeDocumentPath(d *DocumentViewer, arg string) []string {
+	matches, _ := filepath.Glob(expandHome(arg) + "*")
+	var paths []string
+	typed := arg[strings.LastIndex(arg, "/")+1:]
+	for _, m := range matches {
+		if strings.HasPrefix(filepath.Base(m), ".") && !strings.HasPrefix(typed, ".") {
+			continue
+		}
+		// Keep what was typed for ~, rather than the expanded home directory
+		p := arg + strings.TrimPrefix(m, expandHome(arg))
+		if info, err := os.Stat(m); err == nil && info.IsDir() {
+			paths = append(paths, p+"/")
+		} else if isDocumentPath(m) {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// cmdExport saves the current page as a PNG image, or its text when the
+// file name ends in .txt. The default name is the document's with the page
+// number, in the current directory.
+func (d *DocumentViewer) cmdExport(arg string) (int, error) {
+	pdfPage := d.textPages[d.currentPage]
+	path := expandHome(arg)
+	if path == "" {
+		base := strings.TrimSuffix(filepath.Base(d.path), filepath.Ext(d.path))
+		path = fmt.Sprintf("%s-p%d.png", base, pdfPage+1)
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".txt") {
+		text, err := d.pageText(pdfPage)
+		if err != nil {
+			return 0, fmt.Errorf("cannot export page text: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			return 0, fmt.Errorf("cannot export: %v", err)
+		}
+	} else {
+		img, err := d.doc.ImageDPI(pdfPage, exportDPI)
+		if err != nil {
+			return 0, fmt.Errorf("cannot render page: %v", err)
+		}
+		f, err := os.Create(path)
+		if err != nil {
+			return 0, fmt.Errorf("cannot export: %v", err)
+		}
+		err = png.Encode(f, applyDarkMode(img, d.darkMode))
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return 0, fmt.Errorf("cannot export: %v", err)
+		}
+	}
+	d.statusMessage = fmt.Sprintf("Page %s exported to %s", d.pageLabel(pdfPage), path)
+	return 0, nil
+}
+
+// exportDPI is the resolution :export renders pages at.
+const exportDPI = 150
+
+// expandHome expands a leading ~/ to the home directory.
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return home + path[1:]
+		}
+	}
+	return path
+}
+
+// isDocumentPath reports whether a file name has an extension the viewer
+// opens.
+func isDocumentPath(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".pdf", ".epub", ".docx", ".html", ".htm":
+		return true
+	}
+	return false
+}
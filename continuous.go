@@ -0,0 +1,145 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// Continuous mode stacks pages vertically with a small gap and scrolls by
+// terminal rows. The position is the page at the top of the screen
+// (currentPage) plus viewY, how far that page is scrolled up, in pixels.
+
+// continuousScrollLines is how far j/k scroll in continuous mode.
+const continuousScrollLines = 3
+
+// continuousGap returns the gap between pages in pixels (half a line).
+func (d *DocumentViewer) continuousGap() int {
+	_, pixelsPerLine := d.getTerminalCellSize()
+	return int(pixelsPerLine / 2)
+}
+
+// continuousPageHeight returns the height in pixels of a page rendered for
+// continuous mode, without rendering it.
+func (d *DocumentViewer) continuousPageHeight(pdfPage int) int {
+	termWidth, termHeight := d.getTerminalSize()
+	dpi, err := d.pageDPI(pdfPage, termWidth, termHeight-2)
+	if err != nil {
+		return 0
+	}
+	bounds, err := d.doc.Bound(pdfPage)
+	if err != nil {
+		return 0
+	}
+	return int(math.Ceil(float64(bounds.Dy()) * dpi / 72.0))
+}
+
+// scrollContinuous scrolls by a number of terminal lines (negative: up),
+// crossing page boundaries.
+func (d *DocumentViewer) scrollContinuous(lines int) {
+	_, termHeight := d.getTerminalSize()
+	_, pixelsPerLine := d.getTerminalCellSize()
+	gap := d.continuousGap()
+
+	d.viewY += int(float64(lines) * pixelsPerLine)
+	for d.viewY < 0 && d.currentPage > 0 {
+		d.currentPage--
+		d.viewY += d.continuousPageHeight(d.textPages[d.currentPage]) + gap
+	}
+	if d.viewY < 0 {
+		d.viewY = 0
+	}
+
+	for {
+		h := d.continuousPageHeight(d.textPages[d.currentPage])
+		if d.currentPage == len(d.textPages)-1 {
+			// Stop once the end of the last page is at the bottom of the screen
+			viewH := int(float64(termHeight-2) * pixelsPerLine)
+			d.viewY = min(d.viewY, max(0, h-viewH))
+			return
+		}
+		if d.viewY < h+gap {
+			return
+		}
+		d.viewY -= h + gap
+		d.currentPage++
+	}
+}
+
+// renderContinuous composes the visible slices of consecutive pages, from
+// currentPage scrolled up by viewY, into one image and prints it.
+func (d *DocumentViewer) renderContinuous(termWidth, termHeight int) int {
+	if termHeight <= 0 {
+		return 0
+	}
+
+	termType := d.detectTerminalType()
+	pixelsPerChar, pixelsPerLine := d.getTerminalCellSize()
+	viewW := int(float64(termWidth-1) * pixelsPerChar)
+	viewH := int(float64(termHeight) * pixelsPerLine)
+	gap := d.continuousGap()
+
+	type pageSlice struct {
+		page int // PDF page
+		img  image.Image
+		srcY int // first row of the page shown
+		h    int // rows shown
+	}
+	var slices []pageSlice
+	filled := 0
+	maxW := 0
+	y := d.viewY
+	pending := false
+	for i := d.currentPage; i < len(d.textPages) && filled < viewH; i++ {
+		img, err := d.renderPageToImage(d.textPages[i], termWidth, termHeight)
+		if err == errRenderPending {
+			// Keep going so every visible page is requested at once
+			pending = true
+			h := d.continuousPageHeight(d.textPages[i])
+			filled += max(h-y, 1) + gap
+			y = 0
+			continue
+		}
+		if err != nil {
+			return 0
+		}
+		b := img.Bounds()
+		y = min(y, b.Dy()-1)
+		h := min(b.Dy()-y, viewH-filled)
+		slices = append(slices, pageSlice{page: d.textPages[i], img: img, srcY: y, h: h})
+		filled += h + gap
+		maxW = max(maxW, b.Dx())
+		y = 0
+	}
+	if pending || len(slices) == 0 {
+		return 0
+	}
+
+	// Pages wider than the screen (zoomed in) pan with H/L
+	compositeW := min(maxW, viewW)
+	compositeH := min(filled, viewH)
+	d.viewMaxX = maxW - compositeW
+	d.viewX = min(max(d.viewX, 0), d.viewMaxX)
+
+	bgColor := color.RGBA{255, 255, 255, 255}
+	if d.darkMode != "" {
+		bgColor = color.RGBA{30, 30, 30, 255}
+	}
+	composite := image.NewRGBA(image.Rect(0, 0, compositeW, compositeH))
+	draw.Draw(composite, composite.Bounds(), &image.Uniform{bgColor}, image.Point{}, draw.Src)
+
+	dy := 0
+	for _, s := range slices {
+		b := s.img.Bounds()
+		x := (compositeW - b.Dx()) / 2
+		if b.Dx() > compositeW {
+			x = -d.viewX
+		}
+		draw.Draw(composite, image.Rect(x, dy, x+b.Dx(), dy+s.h), s.img, image.Pt(b.Min.X, b.Min.Y+s.srcY), draw.Src)
+		d.addPageArea(s.page, image.Rect(x, dy, x+b.Dx(), dy+s.h), image.Pt(0, s.srcY), termWidth, termHeight)
+		dy += s.h + gap
+	}
+
+	return d.printComposite(composite, termWidth, termHeight, termType)
+}
@@ -10,14 +10,32 @@ import (
 func (d *DocumentViewer) displayCurrentPage() {
 	termWidth, termHeight := d.getTerminalSize()
 	actualPage := d.textPages[d.currentPage]
+	// Set again by pageViewImage when the page is larger than the screen
+	d.viewMaxX, d.viewMaxY = 0, 0
+	// Set when a page image is rendered; neighbours are then prefetched at that size
+	d.renderSize = [2]int{}
+	// Filled in again as the pages are drawn
+	d.screen = screenImage{}
+	// Missing page images are left to the render worker, except while link
+	// hints are painted: followLink waits for the choice right after drawing
+	d.asyncRender = d.renderJobs != nil && d.linkHints == nil
+	defer func() {
+		d.showStatusMessage()
+		d.asyncRender = false
+		d.requestRenders()
+		d.prefetchNeighbours()
+	}()
 
 	// Begin synchronized update (Kitty) - buffers output for atomic display
 	fmt.Print("\033[?2026h")
 
+	// Kitty: take images off the screen, keeping uploaded pages that are still
+	// cached for placing again
+	d.clearKittyPlacements()
+	d.freeKittyImages(false)
 	if d.skipClear {
-		// Reload case: delete Kitty images, move home, overwrite
-		fmt.Print("\033_Ga=d,d=A\033\\") // Delete all Kitty images
-		fmt.Print("\033[H")              // Move cursor home
+		// Reload case: move home, overwrite
+		fmt.Print("\033[H") // Move cursor home
 		d.skipClear = false
 	} else {
 		// Normal case: full screen clear
@@ -28,6 +46,14 @@ func (d *DocumentViewer) displayCurrentPage() {
 	fmt.Print("\033[1G")
 	fmt.Print("\033[0m")
 
+	if d.continuous {
+		d.displayContinuous(termWidth, termHeight)
+		fmt.Print("\033[9999;1H")
+		fmt.Print("\033[?2026l")
+		os.Stdout.Sync()
+		return
+	}
+
 	if d.dualPageMode != "" {
 		d.displayDualPage(termWidth, termHeight)
 		fmt.Print("\033[9999;1H")
@@ -72,7 +98,7 @@ func (d *DocumentViewer) getPageContentType(pageNum int) string {
 	}
 
 	// For EPUBs or PDFs without visual content, use text-based logic
-	text, err := d.doc.Text(pageNum)
+	text, err := d.pageText(pageNum)
 	hasText := err == nil && len(strings.Fields(strings.TrimSpace(text))) >= 3
 	textWordCount := 0
 	if err == nil {
@@ -98,34 +124,26 @@ func (d *DocumentViewer) getPageContentType(pageNum int) string {
 }
 
 func (d *DocumentViewer) highlightSearchMatches(line string) string {
-	if d.searchQuery == "" {
-		return line
-	}
-	lowerLine := strings.ToLower(line)
-	query := d.searchQuery // already lowercase
-	if !strings.Contains(lowerLine, query) {
+	matches := d.searchMatches(line)
+	if len(matches) == 0 {
 		return line
 	}
 
 	var result strings.Builder
 	pos := 0
-	for {
-		idx := strings.Index(lowerLine[pos:], query)
-		if idx < 0 {
-			result.WriteString(line[pos:])
-			break
-		}
-		result.WriteString(line[pos : pos+idx])
+	for _, m := range matches {
+		result.WriteString(line[pos:m[0]])
 		result.WriteString("\033[43;30m") // yellow bg, black text
-		result.WriteString(line[pos+idx : pos+idx+len(query)])
+		result.WriteString(line[m[0]:m[1]])
 		result.WriteString("\033[0m") // reset
-		pos += idx + len(query)
+		pos = m[1]
 	}
+	result.WriteString(line[pos:])
 	return result.String()
 }
 
 func (d *DocumentViewer) displayTextPage(pageNum, termWidth, termHeight int) {
-	text, err := d.doc.Text(pageNum)
+	text, err := d.pageText(pageNum)
 	if err != nil {
 		fmt.Printf("Error extracting text: %v\n", err)
 		return
@@ -182,19 +200,19 @@ func (d *DocumentViewer) displayImagePage(pageNum, termWidth, termHeight int) {
 	availableHeight := termHeight - reserved - verticalPadding
 	fmt.Print("\033[1;1H")
 	fmt.Print("\r\n")
-	fmt.Print("\033[2;1H")
+	d.imageAt(2)
 	imageHeight := d.renderPageImage(pageNum, termWidth, availableHeight)
 	if imageHeight <= 0 {
 		fmt.Print("\033[2;1H")
 		fmt.Printf("  [Image content - page %d]", pageNum+1)
 		fmt.Print("\033[3;1H")
-		fmt.Print("  (Image rendering failed)")
+		if d.renderPending() {
+			fmt.Print("  (Rendering...)")
+		} else {
+			fmt.Print("  (Image rendering failed)")
+		}
 		imageHeight = 2
 	}
-	// Show search match position markers on the right edge
-	if d.searchQuery != "" && imageHeight > 0 {
-		d.drawSearchMarkers(pageNum, termWidth, verticalPadding, imageHeight)
-	}
 	for row := imageHeight + 1 + verticalPadding; row <= termHeight-reserved; row++ {
 		fmt.Printf("\033[%d;1H", row)
 		fmt.Print(strings.Repeat(" ", termWidth))
@@ -213,7 +231,7 @@ func (d *DocumentViewer) displayMixedPage(pageNum, termWidth, termHeight int) {
 	}
 	fmt.Print("\033[1;1H")
 	fmt.Print("\r\n")
-	fmt.Print("\033[2;1H")
+	d.imageAt(2)
 	imageHeight := d.renderPageImage(pageNum, termWidth, maxImageHeight)
 	if imageHeight <= 0 {
 		imageHeight = 0
@@ -228,7 +246,7 @@ func (d *DocumentViewer) displayMixedPage(pageNum, termWidth, termHeight int) {
 	}
 	textAvailable := available - imageHeight - separatorUsed
 	if textAvailable > 0 {
-		text, err := d.doc.Text(pageNum)
+		text, err := d.pageText(pageNum)
 		if err == nil && strings.TrimSpace(text) != "" {
 			effectiveWidth := termWidth - 4 // margin
 			reflowedLines := d.reflowText(text, effectiveWidth)
@@ -265,41 +283,8 @@ func (d *DocumentViewer) displayMixedPage(pageNum, termWidth, termHeight int) {
 	d.displayPageInfo(pageNum, termWidth, "Image+Text")
 }
 
-func (d *DocumentViewer) drawSearchMarkers(pageNum, termWidth, topPadding, imageHeight int) {
-	text, err := d.doc.Text(pageNum)
-	if err != nil || strings.TrimSpace(text) == "" {
-		return
-	}
-	lines := strings.Split(text, "\n")
-	totalLines := len(lines)
-	if totalLines == 0 {
-		return
-	}
-
-	// Find which lines contain matches and map to unique terminal rows
-	markerRows := make(map[int]bool)
-	query := d.searchQuery
-	for i, line := range lines {
-		if strings.Contains(strings.ToLower(line), query) {
-			row := topPadding + 1 + int(float64(i)/float64(totalLines)*float64(imageHeight))
-			if row < topPadding+1 {
-				row = topPadding + 1
-			}
-			if row > topPadding+imageHeight {
-				row = topPadding + imageHeight
-			}
-			markerRows[row] = true
-		}
-	}
-
-	// Draw markers on the right edge
-	for row := range markerRows {
-		fmt.Printf("\033[%d;%dH", row, termWidth)
-		fmt.Print("\033[43m \033[0m") // yellow block
-	}
-}
-
 func (d *DocumentViewer) displayPageInfo(pageNum, termWidth int, contentType string) {
+	d.statusLabel = contentType
 	modeIndicator := ""
 	if d.forceMode != "" {
 		modeIndicator = fmt.Sprintf(" [%s]", d.forceMode)
@@ -320,16 +305,9 @@ func (d *DocumentViewer) displayPageInfo(pageNum, termWidth int, contentType str
 	case "invert":
 		darkIndicator = " [dark:inv]"
 	}
-	searchIndicator := ""
-	if d.searchQuery != "" {
-		if len(d.searchHits) > 0 {
-			searchIndicator = fmt.Sprintf(" [/%s: %d/%d]", d.searchQuery, d.searchHitIdx+1, len(d.searchHits))
-		} else {
-			searchIndicator = fmt.Sprintf(" [/%s: no matches]", d.searchQuery)
-		}
-	}
+	searchIndicator := d.searchIndicator()
 	typeLabel := strings.ToUpper(d.fileType)
-	pageInfo := fmt.Sprintf("Page %d/%d (%s)%s%s%s%s%s - %s", d.currentPage+1, len(d.textPages), contentType, modeIndicator, fitIndicator, scaleIndicator, darkIndicator, searchIndicator, typeLabel)
+	pageInfo := fmt.Sprintf("Page %s (%s)%s%s%s%s%s%s%s%s - %s", d.pagePosition(false), contentType, modeIndicator, fitIndicator, scaleIndicator, d.panIndicator(), darkIndicator, searchIndicator, d.bookmarkIndicator(), d.jumpIndicator(), typeLabel)
 	if len(pageInfo) > termWidth {
 		pageInfo = pageInfo[:termWidth-3] + "..."
 	}
@@ -341,6 +319,20 @@ func (d *DocumentViewer) displayPageInfo(pageNum, termWidth int, contentType str
 	}
 }
 
+// showStatusMessage shows the message of the last command in place of the
+// status bar.
+func (d *DocumentViewer) showStatusMessage() {
+	if d.statusMessage == "" {
+		return
+	}
+	termWidth, termHeight := d.getTerminalSize()
+	msg := []rune(d.statusMessage)
+	if len(msg) > termWidth {
+		msg = msg[:termWidth]
+	}
+	fmt.Printf("\033[%d;1H\033[K%s", termHeight, string(msg))
+}
+
 func (d *DocumentViewer) reflowText(text string, termWidth int) []string {
 	if termWidth <= 0 {
 		termWidth = 80
@@ -489,8 +481,8 @@ func (d *DocumentViewer) wrapText(text string, width int) []string {
 	return lines
 }
 
-func (d *DocumentViewer) showHelp(inputChan <-chan byte) {
-	fmt.Print("\033[2J\033[H") // clear screen
+func (d *DocumentViewer) showHelp(inputChan <-chan keyEvent) {
+	d.clearScreen()
 	termWidth, _ := d.getTerminalSize()
 
 	// Helper: print line with \r\n for raw mode
@@ -501,15 +493,34 @@ func (d *DocumentViewer) showHelp(inputChan <-chan byte) {
 	p(strings.Repeat("=", termWidth))
 	p("")
 	p("Navigation:")
-	p("  j/Space/Down/Right  - Next page")
-	p("  k/Up/Left           - Previous page")
-	p("  g                   - Go to specific page")
+	p("  j/Space/Down/Right  - Next page (scrolls first if the page is taller than the screen)")
+	p("  k/Up/Left           - Previous page (scrolls first if the page is taller than the screen)")
+	p("  H / L               - Pan left / right on pages wider than the screen")
+	p("  g                   - Go to page (printed label like xii/A-3, or #N physical)")
+	p("  gg / G, Home / End  - First / last page")
+	p("  PgDn / PgUp         - Next / previous page (a screen in continuous, 2 pages in dual mode)")
+	p("  NG / N%             - Page N / N percent through the document")
+	p("  N<key>              - Repeat j/k/J/K/n/N N times (e.g. 5j)")
+	p("  o                   - Table of contents")
+	p("  m                   - Bookmark current page (with optional label)")
+	p("  '                   - List bookmarks (Enter jump, r relabel, d delete)")
+	p("  l                   - Follow a link (type the label shown on it)")
+	p("  Ctrl-O / Ctrl-I     - Back / forward in jump history")
 	p("  b                   - Back to file list")
 	p("")
 	p("Search:")
-	p("  /                   - Search text in document")
-	p("  n                   - Next search result")
-	p("  N                   - Previous search result")
+	p("  /                   - Search text in document (case-insensitive unless the query has capitals;")
+	p("                        re:<regexp> for a regular expression, Ctrl-W toggles whole words)")
+	p("  n                   - Next search match")
+	p("  N                   - Previous search match")
+	p("  s                   - List search results (/ filters, Enter goes to one)")
+	p("  ESC                 - Stop a search still running")
+	p("")
+	p("Mouse:")
+	p("  Wheel               - Next / previous page (scrolls zoomed and continuous views)")
+	p("  Shift+Wheel         - Pan left / right")
+	p("  Click               - Follow a link; in dual page mode, bring the page to the front")
+	p("  Drag                - Pan the page")
 	p("")
 	p("Display:")
 	p("  t                   - Toggle view mode (auto/text/image)")
@@ -517,14 +528,17 @@ func (d *DocumentViewer) showHelp(inputChan <-chan byte) {
 	p("  i                   - Toggle dark mode (smart invert, preserves hue)")
 	p("  D                   - Toggle dark mode (simple color invert)")
 	p("  +/-                 - Zoom in/out (10%-200%)")
-	p("  2                   - Cycle dual page (off/vertical/horizontal)")
+	p("  c                   - Toggle continuous scroll (pages stacked, j/k scroll lines, J/K screens)")
+	p("  w                   - Cycle dual page (off/vertical/horizontal)")
 	p("  Shift+Left/Right    - Jump 2 pages (in dual page mode)")
 	p("  r                   - Refresh cell size (after resolution change)")
 	p("  d                   - Show debug info")
+	p("  :                   - Command line: zoom N, fit, view, dark, dual, continuous, set, page,")
+	p("                        open <path>, export [file], help, quit (Tab completes, Up/Down history)")
 	p("  S                   - Open in Skim")
 	p("  P                   - Open in Preview")
 	p("  O                   - Reveal in Finder")
-	p("  h or ?              - Show this help")
+	p("  h, ? or F1          - Show this help")
 	p("  q                   - Quit")
 	p("")
 	p("Features:")
@@ -542,8 +556,8 @@ func (d *DocumentViewer) showHelp(inputChan <-chan byte) {
 	<-inputChan
 }
 
-func (d *DocumentViewer) showDebugInfo(inputChan <-chan byte) {
-	fmt.Print("\033[2J\033[H") // clear screen
+func (d *DocumentViewer) showDebugInfo(inputChan <-chan keyEvent) {
+	d.clearScreen()
 	cols, rows := d.getTerminalSize()
 	cellW, cellH := d.getTerminalCellSize()
 	pixelW, pixelH := d.getTerminalPixelSize()
@@ -554,6 +568,19 @@ func (d *DocumentViewer) showDebugInfo(inputChan <-chan byte) {
 	p(fmt.Sprintf("Cell size: %.1f x %.1f pixels", cellW, cellH))
 	p(fmt.Sprintf("Pixel size (TIOCGWINSZ): %d x %d", pixelW, pixelH))
 	p(fmt.Sprintf("Calculated terminal pixels: %.0f x %.0f", float64(cols)*cellW, float64(rows)*cellH))
+	if mode := d.cellRenderMode(); mode != "" {
+		p(fmt.Sprintf("Image output: text cells (%s)", mode))
+	} else {
+		p(fmt.Sprintf("Image output: %s", d.graphicsProtocol(d.detectTerminalType())))
+	}
+	if d.caps.probed {
+		p(fmt.Sprintf("Terminal reports: %q, Kitty graphics: %v, Sixel: %v", d.caps.name, d.caps.kitty, d.caps.sixel))
+	} else {
+		p("Terminal reports: nothing (no reply to the startup probe)")
+	}
+	if mux := multiplexer(); mux != "" {
+		p(fmt.Sprintf("Multiplexer: %s (terminal: %s)", mux, d.detectTerminalType()))
+	}
 	p(fmt.Sprintf("Fit mode: %s", d.fitMode))
 	p(fmt.Sprintf("Scale factor: %.1f", d.scaleFactor))
 	p("")
@@ -561,6 +588,20 @@ func (d *DocumentViewer) showDebugInfo(inputChan <-chan byte) {
 	<-inputChan
 }
 
+func (d *DocumentViewer) displayContinuous(termWidth, termHeight int) {
+	reserved := 2 // status bar
+
+	d.imageAt(1)
+	if d.renderContinuous(termWidth, termHeight-reserved) <= 0 {
+		fmt.Print("\033[1;1H")
+		fmt.Print(d.renderFailedText())
+	}
+
+	// Status bar
+	fmt.Printf("\033[%d;1H", termHeight)
+	d.displayPageInfo(d.textPages[d.currentPage], termWidth, "Continuous")
+}
+
 func (d *DocumentViewer) displayDualPage(termWidth, termHeight int) {
 	page1 := d.textPages[d.currentPage]
 	hasPage2 := d.currentPage+1 < len(d.textPages)
@@ -577,7 +618,7 @@ func (d *DocumentViewer) displayDualPage(termWidth, termHeight int) {
 func (d *DocumentViewer) displayDualVertical(page1 int, hasPage2 bool, termWidth, termHeight, reserved int) {
 	availableHeight := termHeight - reserved
 
-	fmt.Print("\033[1;1H")
+	d.imageAt(1)
 	var page2 int
 	if hasPage2 {
 		page2 = d.textPages[d.currentPage+1]
@@ -585,7 +626,7 @@ func (d *DocumentViewer) displayDualVertical(page1 int, hasPage2 bool, termWidth
 	imgHeight := d.renderDualComposite(page1, page2, hasPage2, termWidth, availableHeight, "vertical", 1)
 	if imgHeight <= 0 {
 		fmt.Print("\033[1;1H")
-		fmt.Printf("  [Render failed]")
+		fmt.Print(d.renderFailedText())
 	}
 
 	// Status bar
@@ -596,7 +637,7 @@ func (d *DocumentViewer) displayDualVertical(page1 int, hasPage2 bool, termWidth
 func (d *DocumentViewer) displayDualHorizontal(page1 int, hasPage2 bool, termWidth, termHeight, reserved int) {
 	availableHeight := termHeight - reserved
 
-	fmt.Print("\033[1;1H")
+	d.imageAt(1)
 	var page2 int
 	if hasPage2 {
 		page2 = d.textPages[d.currentPage+1]
@@ -604,7 +645,7 @@ func (d *DocumentViewer) displayDualHorizontal(page1 int, hasPage2 bool, termWid
 	imgHeight := d.renderDualComposite(page1, page2, hasPage2, termWidth, availableHeight, "horizontal", 1)
 	if imgHeight <= 0 {
 		fmt.Print("\033[1;1H")
-		fmt.Printf("  [Render failed]")
+		fmt.Print(d.renderFailedText())
 	}
 
 	// Status bar
@@ -613,15 +654,12 @@ func (d *DocumentViewer) displayDualHorizontal(page1 int, hasPage2 bool, termWid
 }
 
 func (d *DocumentViewer) displayDualPageInfo(hasPage2 bool, termWidth int, modeLabel string) {
-	page1Num := d.currentPage + 1
-	page2Num := page1Num + 1
-	totalPages := len(d.textPages)
-
+	d.statusLabel = modeLabel
 	var pageRange string
 	if hasPage2 {
-		pageRange = fmt.Sprintf("Pages %d-%d/%d", page1Num, page2Num, totalPages)
+		pageRange = "Pages " + d.pagePosition(true)
 	} else {
-		pageRange = fmt.Sprintf("Page %d/%d", page1Num, totalPages)
+		pageRange = "Page " + d.pagePosition(false)
 	}
 
 	fitIndicator := fmt.Sprintf(" [fit:%s]", d.fitMode)
@@ -639,18 +677,11 @@ func (d *DocumentViewer) displayDualPageInfo(hasPage2 bool, termWidth int, modeL
 	case "invert":
 		darkIndicator = " [dark:inv]"
 	}
-	searchIndicator := ""
-	if d.searchQuery != "" {
-		if len(d.searchHits) > 0 {
-			searchIndicator = fmt.Sprintf(" [/%s: %d/%d]", d.searchQuery, d.searchHitIdx+1, len(d.searchHits))
-		} else {
-			searchIndicator = fmt.Sprintf(" [/%s: no matches]", d.searchQuery)
-		}
-	}
+	searchIndicator := d.searchIndicator()
 
 	typeLabel := strings.ToUpper(d.fileType)
-	pageInfo := fmt.Sprintf("%s (Image) [%s]%s%s%s%s - %s",
-		pageRange, modeLabel, fitIndicator, scaleIndicator, darkIndicator, searchIndicator, typeLabel)
+	pageInfo := fmt.Sprintf("%s (Image) [%s]%s%s%s%s%s%s - %s",
+		pageRange, modeLabel, fitIndicator, scaleIndicator, darkIndicator, searchIndicator, d.bookmarkIndicator(), d.jumpIndicator(), typeLabel)
 
 	if len(pageInfo) > termWidth {
 		pageInfo = pageInfo[:termWidth-3] + "..."
@@ -6,7 +6,9 @@ import (
 	"image"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"syscall"
@@ -23,41 +25,78 @@ type DocumentViewer struct {
 	path        string
 	oldState    *term.State
 	fileType    string // "pdf" or "epub"
-	tempDir     string // for storing temporary image files
 	forceMode   string // "", "text", or "image" - override auto-detection
 	fitMode      string  // "auto", "height", "width"
 	wantBack     bool    // signal to go back to file picker
 	searchQuery  string  // current search query
-	searchHits   []int     // pages with matches
+	searchRe     *regexp.Regexp // searchQuery compiled; nil when there is no search
+	searchWholeWord bool  // only match whole words
+	searchMatchDiacritics bool // tell letters with diacritics from those without
+	searchHits   []searchHit // occurrences of the search, in document order
 	searchHitIdx int       // current index in searchHits
+	searchResults chan searchReport // reports from the search worker
+	searchCancel  chan struct{}     // closed to stop the running search; nil when none runs
+	searchID      int               // id of the latest search job
+	searchScanned int               // pages the running search has gone through
+	searchJump    bool              // show the first occurrence when the running search finds it
 	scaleFactor  float64   // image scale adjustment (1.0 = default)
 	lastModTime  time.Time // for auto-reload detection
 	cellWidth    float64   // cached cell width in pixels
 	cellHeight   float64   // cached cell height in pixels
 	lastTermCols int       // last known terminal columns (for change detection)
 	lastTermRows int       // last known terminal rows (for change detection)
+	cellReportStale bool   // the terminal was asked for its cell size again and has not answered yet
 	fifoPath      string // path to FIFO for external page jump commands
 	skipClear     bool   // skip screen clear on next display (for smooth reload)
 	htmlPageWidth int    // virtual page width in points for HTML layout (wider = smaller text)
 	isReflowable  bool   // true for HTML (supports layout adjustment)
 	darkMode      string // "": off, "smart": HSL invert, "invert": simple RGB invert
 	dualPageMode  string // "": off, "vertical": stacked, "horizontal": side-by-side
+	continuous    bool   // pages stacked vertically, scrolling by lines
+	fingerprint   string // content hash used to find saved state after a move/rename
+	bookmarks     []bookmark // saved pages, sorted by PDF page
+	jumpList      []int      // jump history as PDF pages (vim-style jumplist)
+	jumpIdx       int        // position in jumpList; len(jumpList) = newest
+	pageLabels    []string   // printed page labels by PDF page; nil if the document has none
+	linkHints     []linkHint // link labels painted on the page while choosing a link
+	pendingKeys   string     // incomplete key sequence, e.g. "12" or "12g"
+	cache          *renderCache     // rendered page images and visual content checks
+	asyncRender    bool             // during a redraw: leave missing page images to the render worker
+	pendingRenders []renderJob      // pages the current redraw is missing
+	renderJobs     chan []renderJob // pages wanted on screen; nil when the render worker is not running
+	prefetchJobs   chan []renderJob // pages for the render worker to prefetch
+	renderDone     chan struct{}    // signalled when the pages wanted on screen are ready
+	renderStopped  chan struct{}    // closed when the render worker gives up on opening the file
+	renderSize     [2]int           // terminal area (cols, rows) the last page image was rendered for
+	viewX         int        // viewport offset into a page wider than the screen (pixels)
+	viewY         int        // viewport offset into a page taller than the screen (pixels)
+	viewMaxX      int        // largest viewX for the page on screen; 0 if it fits
+	viewMaxY      int        // largest viewY for the page on screen; 0 if it fits
+	kittyImages   map[renderKey]kittyUpload // page images uploaded to Kitty
+	lastKittyID   uint32                    // last Kitty image ID handed out
+	kittyScratch  []uint32                  // Kitty images uploaded for one redraw only
+	termType      string                    // cached detectTerminalType result
+	caps          termCaps                  // what the terminal answered to the startup probe; the cell size is updated by later answers
+	cellMode      string                    // text-cell image mode forced with --cells; "" to use one only without graphics
+	screen        screenImage               // where the last redraw put the pages, for mouse clicks
+	drag          *mouseDrag                // left button held down; nil otherwise
+	statusMessage string                    // shown in place of the status bar until the next key
+	statusLabel   string                    // content type in the status bar of the last redraw
+	openPath      string                    // document to open next, set by :open
 }
 
 func NewDocumentViewer(path string) *DocumentViewer {
 	ext := strings.ToLower(filepath.Ext(path))
 	fileType := strings.TrimPrefix(ext, ".")
 
-	tempDir := filepath.Join(os.TempDir(), fmt.Sprintf("docviewer_%d", time.Now().UnixNano()))
-
 	dv := &DocumentViewer{
 		path:         path,
 		fileType:     fileType,
-		tempDir:      tempDir,
 		fitMode:      "height", // default: fit to height
 		scaleFactor:  1.0,
 		htmlPageWidth: 1000, // default: wider than A4 (595pt) so text appears smaller
 		isReflowable: fileType == "html" || fileType == "htm",
+		cache:        newRenderCache(renderCacheBytes),
 	}
 
 	return dv
@@ -70,6 +109,9 @@ func (d *DocumentViewer) Open() error {
 	}
 	d.doc = doc
 
+	// Restore view settings from the last session (before layout, which depends on them)
+	saved := d.restoreState()
+
 	// For reflowable documents (HTML), set the layout with our font size
 	if d.isReflowable {
 		d.applyHTMLLayout()
@@ -84,15 +126,16 @@ func (d *DocumentViewer) Open() error {
 	if len(d.textPages) == 0 {
 		return fmt.Errorf("no pages with extractable content found")
 	}
+	d.loadPageLabels()
+	d.restorePosition(saved)
 	return nil
 }
 
 // applyHTMLLayout calls fz_layout_document to set page width for HTML files.
 // Wider page = more text per line = text appears smaller when scaled to terminal.
 func (d *DocumentViewer) applyHTMLLayout() {
-	// Height proportional to width (A4 ratio ~1.414), em=12 (MuPDF default)
-	h := float64(d.htmlPageWidth) * 1.414
-	layoutDocument(d.doc, float64(d.htmlPageWidth), h, 12)
+	layoutHTML(d.doc, d.htmlPageWidth)
+	d.cache.clear()
 	d.findContentPages()
 }
 
@@ -124,6 +167,7 @@ func (d *DocumentViewer) adjustHTMLZoom(delta int) {
 	if d.currentPage < 0 {
 		d.currentPage = 0
 	}
+	d.restartSearch()
 }
 
 func (d *DocumentViewer) findContentPages() {
@@ -148,8 +192,28 @@ func (d *DocumentViewer) findContentPages() {
 	}
 }
 
+// pageHasVisualContent reports whether a page renders to more than a blank
+// sheet. Results are cached since this renders the whole page. During an
+// asynchronous redraw an unchecked page is assumed to be visual (rendered as
+// an image) until the render worker has checked it.
 func (d *DocumentViewer) pageHasVisualContent(pageNum int) bool {
-	img, err := d.doc.Image(pageNum)
+	if visual, ok := d.cache.visualContent(pageNum); ok {
+		return visual
+	}
+	if d.asyncRender {
+		d.queueRender(renderJob{key: renderKey{page: pageNum}, detect: true})
+		return true
+	}
+	gen := d.cache.generation()
+	visual := d.detectVisualContent(d.doc, pageNum)
+	d.cache.putVisualContent(pageNum, visual, gen)
+	return visual
+}
+
+// detectVisualContent renders a page of doc and checks it for non-blank
+// pixels. It does not touch viewer state, so the render worker can use it.
+func (d *DocumentViewer) detectVisualContent(doc *fitz.Document, pageNum int) bool {
+	img, err := doc.Image(pageNum)
 	if err != nil {
 		return false
 	}
@@ -256,10 +320,14 @@ func (d *DocumentViewer) checkColorVariance(img image.Image) float64 {
 
 func (d *DocumentViewer) Run() bool {
 	defer d.doc.Close()
-	defer d.cleanup()
 
-	// Cache cell size before entering raw mode (for Kitty query)
+	// Ask the terminal what it supports before the input goroutine reads
+	// from it, and cache the cell size
+	d.caps = probeTerminal()
 	d.cellWidth, d.cellHeight = d.detectCellSize()
+	if old, changed := d.enablePassthrough(); changed {
+		defer restorePassthrough(old)
+	}
 
 	oldState, err := d.setRawMode()
 	if err != nil {
@@ -267,13 +335,25 @@ func (d *DocumentViewer) Run() bool {
 		return false
 	}
 	defer d.restoreTerminal(oldState)
+	defer d.freeKittyImages(true)
 	fmt.Print("\033[?25l")
 	defer fmt.Print("\033[?25h") // Show cursor on exit
-
-	d.currentPage = 0
-
-	// Channel for input from goroutine
-	inputChan := make(chan byte, 1)
+	if d.caps.kittyKeys {
+		// Escape and modified keys as unambiguous sequences
+		fmt.Print("\033[>1u")
+		defer fmt.Print("\033[<u")
+	}
+	fmt.Print(mouseOn)
+	defer fmt.Print(mouseOff)
+
+	// Remember page and view settings for the next session
+	defer d.saveState()
+	defer d.cancelSearch()
+
+	// Channel for input from goroutine, and one for the cell size the
+	// terminal reports, which overlays reading keys must not get
+	inputChan := make(chan keyEvent, 1)
+	cellSizes := make(chan keyEvent, 1)
 	stopChan := make(chan struct{})
 	defer close(stopChan)
 
@@ -287,14 +367,35 @@ func (d *DocumentViewer) Run() bool {
 	// FIFO listener goroutine
 	go d.fifoListener(pageChan, stopChan)
 
-	// Input reader goroutine
+	// Render worker draws pages with its own document handle
+	d.renderJobs = make(chan []renderJob, 1)
+	d.prefetchJobs = make(chan []renderJob, 1)
+	d.renderDone = make(chan struct{}, 1)
+	d.renderStopped = make(chan struct{})
+	go d.renderWorker(stopChan)
+
+	// Input reader goroutine; it checks for stopChan between polls, so the
+	// file picker gets the keys typed after the viewer is closed
 	go func() {
+		keys := newKeyReader()
 		for {
-			char := d.readSingleChar()
+			ev, ok := keys.next(50 * time.Millisecond)
+			if !ok {
+				select {
+				case <-stopChan:
+					return
+				default:
+					continue
+				}
+			}
+			events := inputChan
+			if ev.key == keyCellSize {
+				events = cellSizes
+			}
 			select {
 			case <-stopChan:
 				return
-			case inputChan <- char:
+			case events <- ev:
 			}
 		}
 	}()
@@ -303,31 +404,79 @@ func (d *DocumentViewer) Run() bool {
 	ticker := time.NewTicker(500 * time.Millisecond)
 	defer ticker.Stop()
 
+	// Terminal resizes, redrawn once they settle
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+	defer signal.Stop(winch)
+	var resizeTimeout <-chan time.Time
+
 	d.displayCurrentPage()
 
+	// Set when mouse events changed the view but a redraw was skipped
+	mouseRedraw := false
+
 	for {
-		// Wait for input, page jump, or reload tick
+		// Wait for input, page jump, resize, or reload tick
 		select {
-		case char := <-inputChan:
-			action := d.handleInput(char)
+		case ev := <-inputChan:
+			d.statusMessage = ""
+			if ev.key == keyMouse {
+				mouseRedraw = d.handleMouse(ev) || mouseRedraw
+				// Drags report every cell moved: draw once they catch up
+				if mouseRedraw && len(inputChan) == 0 {
+					mouseRedraw = false
+					d.displayCurrentPage()
+				}
+				continue
+			}
+			action := d.handleKey(ev)
 			if action == 1 {
 				fmt.Print("\033[2J\033[H")
 				return d.wantBack
 			}
-			switch action {
-			case -1:
-				d.startSearch(inputChan)
-			case -2:
-				d.goToPage(inputChan)
-			case -3:
-				d.showHelp(inputChan)
-			case -4:
-				d.showDebugInfo(inputChan)
+			if action == 0 && d.pendingKeys != "" {
+				// Sequence not complete yet: only update the status bar
+				d.drawPendingKeys()
+				continue
 			}
+			if action == 0 && len(inputChan) > 0 {
+				// More keys already waiting (key held down): skip drawing
+				// pages the user is only passing through
+				d.drawStatusOnly()
+				continue
+			}
+			if d.runAction(action, inputChan) == 1 {
+				fmt.Print("\033[2J\033[H")
+				return d.wantBack
+			}
+			d.displayCurrentPage()
+		case <-d.renderDone:
 			d.displayCurrentPage()
+		case <-d.renderStopped:
+			// The file cannot be opened again (deleted or moved), but d.doc
+			// still has it open: render with that from now on
+			d.renderJobs, d.prefetchJobs, d.renderStopped = nil, nil, nil
+			d.displayCurrentPage()
+		case ev := <-cellSizes:
+			if d.setReportedCellSize(float64(ev.cellWidth), float64(ev.cellHeight)) {
+				d.displayCurrentPage()
+			}
+		case report := <-d.searchResults:
+			if d.addSearchReport(report) {
+				d.displayCurrentPage()
+			} else {
+				d.drawSearchProgress()
+			}
 		case page := <-pageChan:
+			d.recordJump()
 			d.jumpToPage(page)
 			d.displayCurrentPage()
+		case <-winch:
+			resizeTimeout = time.After(resizeDebounce)
+		case <-resizeTimeout:
+			resizeTimeout = nil
+			d.refreshCellSize()
+			d.displayCurrentPage()
 		case <-ticker.C:
 			if d.checkAndReload() {
 				d.displayCurrentPage()
@@ -336,10 +485,34 @@ func (d *DocumentViewer) Run() bool {
 	}
 }
 
-func (d *DocumentViewer) cleanup() {
-	if d.tempDir != "" {
-		os.RemoveAll(d.tempDir)
+// runAction opens the prompt or overlay requested by a handleInput action
+// code. It returns 1 when a command asks to quit.
+func (d *DocumentViewer) runAction(action int, inputChan <-chan keyEvent) int {
+	switch action {
+	case -1:
+		d.startSearch(inputChan)
+	case -2:
+		d.goToPage(inputChan)
+	case -3:
+		d.showHelp(inputChan)
+	case -4:
+		d.showDebugInfo(inputChan)
+	case -5:
+		d.showOutline(inputChan)
+	case -6:
+		d.addBookmark(inputChan)
+	case -7:
+		d.showBookmarks(inputChan)
+	case -8:
+		d.followLink(inputChan)
+	case -9:
+		if action := d.commandLine(inputChan); action != 0 {
+			return action
+		}
+	case -10:
+		d.showSearchResults(inputChan)
 	}
+	return 0
 }
 
 func (d *DocumentViewer) setupFIFO() {
@@ -398,6 +571,7 @@ func (d *DocumentViewer) jumpToPage(page int) {
 	// page is 1-indexed from external command
 	// Find the index in textPages that corresponds to this PDF page
 	targetPdfPage := page - 1 // Convert to 0-indexed PDF page
+	d.viewY = 0               // start at the top of the page
 
 	// First try exact match
 	for i, pdfPage := range d.textPages {
@@ -486,11 +660,16 @@ func (d *DocumentViewer) checkAndReload() bool {
 			d.textPages = oldPages
 			d.currentPage = oldPage
 			doc.Close()
+			if d.isReflowable {
+				// The layout cleared the cache, which stopped the search
+				d.restartSearch()
+			}
 			return false
 		}
 
 		// New doc is good, close old one
 		oldDoc.Close()
+		d.cache.clear()
 
 		// Restore page position (clamp to valid range)
 		if savedPage >= len(d.textPages) {
@@ -500,14 +679,18 @@ func (d *DocumentViewer) checkAndReload() bool {
 			savedPage = 0
 		}
 		d.currentPage = savedPage
+		d.loadPageLabels()
+		d.restartSearch()
 		d.skipClear = true // Skip screen clear to avoid blink on reload
 		return true
 	}
 	return false
 }
 
-// handleInput returns: 0 = continue, 1 = quit, -1 = search, -2 = goto page
-func (d *DocumentViewer) handleInput(c byte) int {
+// handleInput returns: 0 = continue, 1 = quit, -1 = search, -2 = goto page,
+// -3 = help, -4 = debug info, -5 = table of contents, -6 = add bookmark,
+// -7 = bookmark list, -8 = follow link, -9 = command line
+func (d *DocumentViewer) handleInput(c rune) int {
 	switch c {
 	case 'q':
 		return 1
@@ -515,17 +698,27 @@ func (d *DocumentViewer) handleInput(c byte) int {
 		d.wantBack = true
 		return 1
 	case 'j', ' ':
-		if d.currentPage < len(d.textPages)-1 {
-			d.currentPage++
-		}
+		d.scrollDown()
 	case 'k':
-		if d.currentPage > 0 {
-			d.currentPage--
-		}
+		d.scrollUp()
+	case 'H':
+		d.panHorizontal(-1)
+	case 'L':
+		d.panHorizontal(1)
 	case 'g':
 		return -2 // signal: go to page
 	case 'h', '?':
 		return -3 // signal: show help
+	case 'o':
+		return -5 // signal: show table of contents
+	case 'm':
+		return -6 // signal: add bookmark
+	case '\'':
+		return -7 // signal: show bookmarks
+	case 'l':
+		return -8 // signal: follow link
+	case ':':
+		return -9 // signal: command line
 	case 't':
 		d.toggleViewMode()
 	case 'f':
@@ -539,29 +732,29 @@ func (d *DocumentViewer) handleInput(c byte) int {
 		}
 	case '/':
 		return -1 // signal: start search
+	case 's':
+		return -10 // signal: show search results
 	case 'n':
-		d.nextSearchHit()
+		d.nextSearchHit(1)
 	case 'N':
-		d.prevSearchHit()
+		d.prevSearchHit(1)
+	case 15: // Ctrl-O: back in jump history
+		d.jumpBack()
+	case 9: // Ctrl-I/Tab: forward in jump history
+		d.jumpForward()
 	case '+', '=':
 		if d.isReflowable {
 			// Narrower page = larger text
 			d.adjustHTMLZoom(-100)
 		} else {
-			d.scaleFactor += 0.1
-			if d.scaleFactor > 2.0 {
-				d.scaleFactor = 2.0
-			}
+			d.setZoomPercent(d.zoomPercent() + 10)
 		}
 	case '-', '_':
 		if d.isReflowable {
 			// Wider page = smaller text
 			d.adjustHTMLZoom(100)
 		} else {
-			d.scaleFactor -= 0.1
-			if d.scaleFactor < 0.1 {
-				d.scaleFactor = 0.1
-			}
+			d.setZoomPercent(d.zoomPercent() - 10)
 		}
 	case 'r':
 		// Refresh cell size (useful after resolution/monitor change)
@@ -588,32 +781,40 @@ func (d *DocumentViewer) handleInput(c byte) int {
 	case 'd':
 		// Debug: show detected dimensions
 		return -4 // signal: show debug info
-	case '2':
+	case 'w':
 		switch d.dualPageMode {
 		case "":
-			d.dualPageMode = "vertical"
+			d.setDualPageMode("vertical")
 		case "vertical":
-			d.dualPageMode = "horizontal"
+			d.setDualPageMode("horizontal")
 		default:
-			d.dualPageMode = ""
-		}
-	case 'J': // Shift+Down/Right: jump 2 pages (in dual mode)
-		if d.dualPageMode != "" {
+			d.setDualPageMode("")
+		}
+	case 'c':
+		d.setContinuous(!d.continuous)
+	case 'J': // Shift+Down/Right: jump 2 pages (in dual mode), a screen (continuous)
+		if d.continuous {
+			_, rows := d.getTerminalSize()
+			d.scrollContinuous(rows - 3)
+		} else if d.dualPageMode != "" {
 			if d.currentPage < len(d.textPages)-2 {
 				d.currentPage += 2
 			} else if d.currentPage < len(d.textPages)-1 {
 				d.currentPage = len(d.textPages) - 1
 			}
 		}
-	case 'K': // Shift+Up/Left: jump back 2 pages (in dual mode)
-		if d.dualPageMode != "" {
+	case 'K': // Shift+Up/Left: jump back 2 pages (in dual mode), a screen (continuous)
+		if d.continuous {
+			_, rows := d.getTerminalSize()
+			d.scrollContinuous(-(rows - 3))
+		} else if d.dualPageMode != "" {
 			if d.currentPage >= 2 {
 				d.currentPage -= 2
 			} else {
 				d.currentPage = 0
 			}
 		}
-	case 27: // ESC key (arrow keys handled in readSingleChar)
+	case 27: // ESC key (arrow keys are mapped in handleKey)
 		// Do nothing for plain ESC
 	}
 	return 0
@@ -644,31 +845,41 @@ end tell
 	}
 }
 
-func (d *DocumentViewer) startSearch(inputChan <-chan byte) {
+func (d *DocumentViewer) startSearch(inputChan <-chan keyEvent) {
 	_, rows := d.getTerminalSize()
-	fmt.Printf("\033[%d;1H\033[K", rows) // bottom line
-	fmt.Print("\033[?25h")                // show cursor
-	fmt.Print("Search: ")
+	var query []rune
+	redraw := func() {
+		fmt.Printf("\033[%d;1H\033[K", rows) // bottom line
+		if d.searchWholeWord {
+			fmt.Print("Search [word]: ")
+		} else {
+			fmt.Print("Search: ")
+		}
+		fmt.Print(string(query))
+	}
+	fmt.Print("\033[?25h") // show cursor
+	redraw()
 
-	var query []byte
 	for {
-		ch := <-inputChan
-		switch ch {
-		case 13, 10: // Enter
+		ev := <-inputChan
+		switch ev.key {
+		case keyEnter:
 			goto done
-		case 27: // Escape - cancel
+		case keyEscape: // cancel
 			fmt.Print("\033[?25l")
 			return
-		case 127, 8: // Backspace
+		case keyBackspace:
 			if len(query) > 0 {
 				query = query[:len(query)-1]
-				fmt.Printf("\033[%d;1H\033[K", rows)
-				fmt.Printf("Search: %s", string(query))
+				redraw()
 			}
 		default:
-			if ch >= 32 && ch < 127 {
-				query = append(query, ch)
-				fmt.Printf("%c", ch)
+			if ev.char() == 23 { // Ctrl-W: toggle whole-word matching
+				d.searchWholeWord = !d.searchWholeWord
+				redraw()
+			} else if r := ev.text(); r != 0 {
+				query = append(query, r)
+				fmt.Printf("%c", r)
 			}
 		}
 	}
@@ -677,63 +888,37 @@ done:
 	queryStr := strings.TrimSpace(string(query))
 
 	if queryStr == "" {
+		d.cancelSearch()
 		d.searchQuery = ""
+		d.searchRe = nil
 		d.searchHits = nil
 		return
 	}
 
-	d.searchQuery = strings.ToLower(queryStr)
-	d.searchHits = nil
-	d.searchHitIdx = 0
-
-	// Search all pages
-	for _, pageNum := range d.textPages {
-		text, err := d.doc.Text(pageNum)
-		if err == nil && strings.Contains(strings.ToLower(text), d.searchQuery) {
-			d.searchHits = append(d.searchHits, pageNum)
-		}
-	}
-
-	if len(d.searchHits) > 0 {
-		// Jump to first hit
-		for i, p := range d.textPages {
-			if p == d.searchHits[0] {
-				d.currentPage = i
-				break
-			}
-		}
+	re, err := compileSearch(queryStr, d.searchFold(queryStr))
+	if err != nil {
+		d.statusMessage = "Invalid regular expression: " + err.Error()
+		return
 	}
+	d.searchQuery = queryStr
+	d.searchRe = re
+	d.beginSearch(true)
 }
 
-func (d *DocumentViewer) nextSearchHit() {
+func (d *DocumentViewer) nextSearchHit(count int) {
 	if len(d.searchHits) == 0 {
 		return
 	}
-	d.searchHitIdx = (d.searchHitIdx + 1) % len(d.searchHits)
-	targetPage := d.searchHits[d.searchHitIdx]
-	for i, p := range d.textPages {
-		if p == targetPage {
-			d.currentPage = i
-			break
-		}
-	}
+	d.searchHitIdx = (d.searchHitIdx + count) % len(d.searchHits)
+	d.showSearchHit()
 }
 
-func (d *DocumentViewer) prevSearchHit() {
+func (d *DocumentViewer) prevSearchHit(count int) {
 	if len(d.searchHits) == 0 {
 		return
 	}
-	d.searchHitIdx--
-	if d.searchHitIdx < 0 {
-		d.searchHitIdx = len(d.searchHits) - 1
-	}
-	targetPage := d.searchHits[d.searchHitIdx]
-	for i, p := range d.textPages {
-		if p == targetPage {
-			d.currentPage = i
-			break
-		}
-	}
+	d.searchHitIdx = ((d.searchHitIdx-count)%len(d.searchHits) + len(d.searchHits)) % len(d.searchHits)
+	d.showSearchHit()
 }
 
 func (d *DocumentViewer) toggleViewMode() {
@@ -747,41 +932,68 @@ func (d *DocumentViewer) toggleViewMode() {
 	}
 }
 
+// setDualPageMode shows two pages at once, "vertical" (stacked) or
+// "horizontal" (side by side), or one with "". Continuous mode is left.
+func (d *DocumentViewer) setDualPageMode(mode string) {
+	d.continuous = false
+	d.dualPageMode = mode
+}
 
-func (d *DocumentViewer) goToPage(inputChan <-chan byte) {
-	_, rows := d.getTerminalSize()
-	fmt.Printf("\033[%d;1H\033[K", rows)
-	fmt.Print("\033[?25h")
-	fmt.Printf("Go to page (1-%d): ", len(d.textPages))
+// setContinuous turns continuous scrolling on or off, leaving dual-page mode.
+func (d *DocumentViewer) setContinuous(on bool) {
+	d.continuous = on
+	d.dualPageMode = ""
+	d.viewY = 0
+}
 
-	var input []byte
-	for {
-		ch := <-inputChan
-		switch ch {
-		case 13, 10: // Enter
-			goto done
-		case 27: // Escape
-			fmt.Print("\033[?25l")
-			return
-		case 127, 8: // Backspace
-			if len(input) > 0 {
-				input = input[:len(input)-1]
-				fmt.Printf("\033[%d;1H\033[K", rows)
-				fmt.Printf("Go to page (1-%d): %s", len(d.textPages), string(input))
-			}
-		default:
-			if ch >= '0' && ch <= '9' {
-				input = append(input, ch)
-				fmt.Printf("%c", ch)
-			}
-		}
+// zoomPercent returns the zoom level: the scale factor for fixed-layout
+// documents, the text size relative to an A4 page width for HTML.
+func (d *DocumentViewer) zoomPercent() int {
+	if d.isReflowable {
+		return 595 * 100 / d.htmlPageWidth
 	}
-done:
-	fmt.Print("\033[?25l")
-	var num int
-	if _, err := fmt.Sscanf(string(input), "%d", &num); err == nil {
-		if num >= 1 && num <= len(d.textPages) {
-			d.currentPage = num - 1
-		}
+	return int(d.scaleFactor*100 + 0.5)
+}
+
+// setZoomPercent sets the zoom level, clamped to 10%-200% for fixed-layout
+// documents.
+func (d *DocumentViewer) setZoomPercent(pct int) {
+	if d.isReflowable {
+		d.adjustHTMLZoom(595*100/max(pct, 1) - d.htmlPageWidth)
+		return
+	}
+	d.scaleFactor = float64(min(max(pct, 10), 200)) / 100
+}
+
+
+func (d *DocumentViewer) goToPage(inputChan <-chan keyEvent) {
+	prompt := fmt.Sprintf("Go to page (1-%d): ", d.doc.NumPage())
+	if d.pageLabels != nil {
+		prompt = fmt.Sprintf("Go to page (label, or #1-%d): ", d.doc.NumPage())
+	}
+
+	// g as the first key goes to the first page, completing vim's gg
+	_, rows := d.getTerminalSize()
+	fmt.Printf("\033[%d;1H\033[K%s\033[?25h", rows, prompt)
+	ev := <-inputChan
+	initial := ""
+	switch {
+	case ev.char() == 'g':
+		fmt.Print("\033[?25l")
+		d.goToPageNumber(0, 1)
+		return
+	case ev.key == keyEscape || ev.key == keyEnter:
+		fmt.Print("\033[?25l")
+		return
+	case ev.text() != 0:
+		initial = string(ev.text())
+	}
+	input, ok := d.promptLine(inputChan, prompt, initial)
+	if !ok {
+		return
+	}
+	if pdfPage, found := d.resolvePageInput(input); found {
+		d.recordJump()
+		d.jumpToPage(pdfPage + 1)
 	}
 }
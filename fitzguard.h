@@ -0,0 +1,32 @@
+// MuPDF reports errors by longjmp-ing to the innermost fz_try. go-fitz
+// guards the calls it makes, but the viewer calls a few MuPDF functions
+// directly, and an error there with no handler aborts the process. The C
+// shims wrapping those calls catch errors with dv_try/dv_catch, which are
+// fz_try/fz_catch from <mupdf/fitz/context.h> (not on our include path).
+
+#ifndef DV_FITZGUARD_H
+#define DV_FITZGUARD_H
+
+#include <setjmp.h>
+
+// fz_jmp_buf: MuPDF uses sigsetjmp wherever it is available
+#if defined(__APPLE__) || ((defined(__unix) || defined(__unix__)) && !defined(__EMSCRIPTEN__))
+typedef sigjmp_buf dv_jmp_buf;
+#define dv_setjmp(buf) sigsetjmp(buf, 0)
+#else
+typedef jmp_buf dv_jmp_buf;
+#define dv_setjmp(buf) setjmp(buf)
+#endif
+
+extern dv_jmp_buf *fz_push_try(void *ctx);
+extern int fz_do_try(void *ctx);
+extern int fz_do_catch(void *ctx);
+extern void fz_ignore_error(void *ctx);
+
+#define dv_try(ctx) if (!dv_setjmp(*fz_push_try(ctx))) if (fz_do_try(ctx)) do
+#define dv_catch(ctx) while (0); if (fz_do_catch(ctx))
+
+// dv_failed is returned by the shims when MuPDF throws an error.
+#define dv_failed (-1)
+
+#endif
@@ -5,7 +5,10 @@ go 1.24.6
 require (
 	github.com/blacktop/go-termimg v0.1.24
 	github.com/gen2brain/go-fitz v1.24.15
+	github.com/mattn/go-sixel v0.0.5
 	github.com/sahilm/fuzzy v0.1.1
+	golang.org/x/image v0.32.0
+	golang.org/x/sys v0.38.0
 	golang.org/x/term v0.37.0
 )
 
@@ -24,11 +27,8 @@ require (
 	github.com/lucasb-eyer/go-colorful v1.3.0 // indirect
 	github.com/makeworld-the-better-one/dither/v2 v2.4.0 // indirect
 	github.com/mattn/go-runewidth v0.0.19 // indirect
-	github.com/mattn/go-sixel v0.0.5 // indirect
 	github.com/nfnt/resize v0.0.0-20180221191011-83c6a9932646 // indirect
 	github.com/rivo/uniseg v0.4.7 // indirect
 	github.com/soniakeys/quant v1.0.0 // indirect
 	github.com/xo/terminfo v0.0.0-20220910002029-abceb7e1c41e // indirect
-	golang.org/x/image v0.32.0 // indirect
-	golang.org/x/sys v0.38.0 // indirect
 )
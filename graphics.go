@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"math/rand/v2"
+	"os"
+
+	"github.com/blacktop/go-termimg"
+	"github.com/mattn/go-sixel"
+)
+
+// Images go to the terminal straight from memory: Kitty gets raw RGBA
+// pixels (zlib-compressed when that is smaller), Sixel terminals a Sixel
+// encoding of the image. Other terminals are left to go-termimg.
+//
+// In Kitty, cached page images are uploaded once under an image ID of their
+// own and then only placed (a=p), cropped to the part on screen, so going
+// back to a page or panning costs one escape sequence. Uploads are freed
+// once their page drops out of the render cache.
+//
+// Inside a terminal multiplexer the sequences go through passthrough.go.
+
+// kittyChunkSize is the largest base64 payload per Kitty graphics escape.
+const kittyChunkSize = 4096
+
+// graphicsProtocol returns how images are sent to a terminal type: "kitty",
+// "sixel" or "termimg" (go-termimg's detection, e.g. iTerm2), or "" if it
+// has no graphics support. Kitty and Sixel go by what the terminal answered
+// to the startup probe, when it did and is not behind a multiplexer.
+func (d *DocumentViewer) graphicsProtocol(termType string) string {
+	protocol := ""
+	switch termType {
+	case "kitty":
+		protocol = "kitty"
+	case "foot", "xterm":
+		protocol = "sixel"
+	case "wezterm", "iterm2":
+		return "termimg"
+	}
+	if !d.caps.probed || multiplexer() != "" {
+		return protocol
+	}
+	switch {
+	case d.caps.kitty:
+		return "kitty"
+	case d.caps.sixel:
+		return "sixel"
+	}
+	return ""
+}
+
+// printImage draws img at the cursor, cols x rows cells large, with a
+// protocol other than Kitty's (see placeKittyPage). The image is expected to
+// be rendered at the size it is shown at.
+func printImage(img image.Image, cols, rows int, protocol string) error {
+	switch protocol {
+	case "sixel":
+		// Encoded in full first: passthrough wraps whole sequences
+		var buf bytes.Buffer
+		if err := sixel.NewEncoder(&buf).Encode(img); err != nil {
+			return err
+		}
+		_, err := graphicsWriter(os.Stdout).Write(buf.Bytes())
+		return err
+	}
+	b := img.Bounds()
+	return termimg.New(img).WidthPixels(b.Dx()).HeightPixels(b.Dy()).Scale(termimg.ScaleFit).Print()
+}
+
+// kittyUpload is a page image stored in the terminal.
+type kittyUpload struct {
+	id     uint32
+	origin image.Point // bounds origin of the uploaded image
+}
+
+// placeKittyPage shows the part view of the cached page image key at the
+// cursor over cols x rows cells, uploading the page first if needed. Images
+// that are not cached pages (key is nil) are sent as they are. Inside tmux
+// the image is shown with placeholder cells, each row starting offset
+// columns from the left edge.
+func (d *DocumentViewer) placeKittyPage(key *renderKey, view image.Image, cols, rows, offset int) error {
+	out := bufio.NewWriterSize(os.Stdout, 64<<10)
+	w := graphicsWriter(out)
+	placeholders := multiplexer() == "tmux"
+
+	var up kittyUpload
+	var uploaded, cached bool
+	var img image.Image
+	if key != nil {
+		up, uploaded = d.kittyImages[*key]
+		if !uploaded {
+			// May have been evicted by a prefetch meanwhile
+			img, cached = d.cache.get(*key)
+		}
+	}
+	switch {
+	case uploaded:
+	case cached:
+		up = kittyUpload{id: d.nextKittyID(), origin: img.Bounds().Min}
+		if err := writeKittyImage(w, img, fmt.Sprintf("a=t,i=%d", up.id)); err != nil {
+			return err
+		}
+		if d.kittyImages == nil {
+			d.kittyImages = make(map[renderKey]kittyUpload)
+		}
+		d.kittyImages[*key] = up
+	case placeholders:
+		// Placeholders need an image ID; this one is freed on the next redraw
+		up = kittyUpload{id: d.nextKittyID(), origin: view.Bounds().Min}
+		if err := writeKittyImage(w, view, fmt.Sprintf("a=t,i=%d", up.id)); err != nil {
+			return err
+		}
+		d.kittyScratch = append(d.kittyScratch, up.id)
+	default:
+		if err := writeKittyImage(w, view, fmt.Sprintf("a=T,c=%d,r=%d", cols, rows)); err != nil {
+			return err
+		}
+		return out.Flush()
+	}
+
+	src := view.Bounds().Sub(up.origin)
+	if placeholders {
+		// One virtual placement per image, replaced by the next one
+		fmt.Fprintf(w, "\033_Ga=p,U=1,i=%d,p=1,x=%d,y=%d,w=%d,h=%d,c=%d,r=%d,q=2\033\\",
+			up.id, src.Min.X, src.Min.Y, src.Dx(), src.Dy(), cols, rows)
+		if err := writeKittyPlaceholders(out, up.id, cols, rows, offset); err != nil {
+			return err
+		}
+	} else {
+		fmt.Fprintf(w, "\033_Ga=p,i=%d,x=%d,y=%d,w=%d,h=%d,c=%d,r=%d,q=2\033\\",
+			up.id, src.Min.X, src.Min.Y, src.Dx(), src.Dy(), cols, rows)
+	}
+	return out.Flush()
+}
+
+// nextKittyID hands out a new Kitty image ID. IDs are shared by everything
+// running in the window, so each viewer starts at a random one, where
+// another viewer's IDs are very unlikely to come near.
+func (d *DocumentViewer) nextKittyID() uint32 {
+	if d.lastKittyID == 0 {
+		d.lastKittyID = rand.Uint32()
+	}
+	d.lastKittyID++
+	if d.lastKittyID == 0 { // wrapped around; 0 is not an ID
+		d.lastKittyID++
+	}
+	return d.lastKittyID
+}
+
+// freeKittyImages frees uploaded page images that are no longer cached, or
+// all of them, and images uploaded for an earlier redraw.
+func (d *DocumentViewer) freeKittyImages(all bool) {
+	w := graphicsWriter(os.Stdout)
+	for key, up := range d.kittyImages {
+		if all || !d.cache.has(key) {
+			fmt.Fprintf(w, "\033_Ga=d,d=I,i=%d,q=2\033\\", up.id)
+			delete(d.kittyImages, key)
+		}
+	}
+	for _, id := range d.kittyScratch {
+		fmt.Fprintf(w, "\033_Ga=d,d=I,i=%d,q=2\033\\", id)
+	}
+	d.kittyScratch = nil
+}
+
+// clearKittyPlacements removes all images from the screen, keeping uploaded
+// pages for reuse. Kitty frees images whose placements are erased by a
+// screen clear, so this has to come first.
+func (d *DocumentViewer) clearKittyPlacements() {
+	if d.graphicsProtocol(d.detectTerminalType()) == "kitty" {
+		fmt.Fprint(graphicsWriter(os.Stdout), "\033_Ga=d,d=a,q=2\033\\")
+	}
+}
+
+// clearScreen clears the screen for an overlay or prompt.
+func (d *DocumentViewer) clearScreen() {
+	d.clearKittyPlacements()
+	fmt.Print("\033[2J\033[H")
+}
+
+// writeKittyImage writes img as a Kitty graphics transmission; keys gives
+// the action and placement.
+func writeKittyImage(w io.Writer, img image.Image, keys string) error {
+	data, compressed, err := kittyPixels(img)
+	if err != nil {
+		return err
+	}
+	b := img.Bounds()
+	control := fmt.Sprintf("%s,f=32,s=%d,v=%d,q=2", keys, b.Dx(), b.Dy())
+	if compressed {
+		control += ",o=z"
+	}
+	return writeKittyChunks(w, control, data)
+}
+
+// kittyPixels returns the RGBA pixels of img for transmission, zlib-compressed
+// unless that does not make them smaller (photos).
+func kittyPixels(img image.Image) ([]byte, bool, error) {
+	b := img.Bounds()
+	rgba := rgbaImage(img)
+	if rgba.Stride != 4*b.Dx() {
+		rgba = image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+	}
+	pix := rgba.Pix[:4*b.Dx()*b.Dy()]
+
+	var buf bytes.Buffer
+	zw, err := zlib.NewWriterLevel(&buf, zlib.BestSpeed)
+	if err != nil {
+		return nil, false, err
+	}
+	if _, err := zw.Write(pix); err != nil {
+		return nil, false, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, false, err
+	}
+	if buf.Len() >= len(pix)*3/4 {
+		return pix, false, nil
+	}
+	return buf.Bytes(), true, nil
+}
+
+// rgbaImage returns img as an *image.RGBA, converting it if needed.
+func rgbaImage(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok {
+		return rgba
+	}
+	b := img.Bounds()
+	rgba := image.NewRGBA(b)
+	draw.Draw(rgba, b, img, b.Min, draw.Src)
+	return rgba
+}
+
+// writeKittyChunks base64-encodes data and sends it in chunks, the first one
+// carrying the control keys.
+func writeKittyChunks(w io.Writer, control string, data []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for first := true; first || len(encoded) > 0; first = false {
+		chunk := encoded[:min(kittyChunkSize, len(encoded))]
+		encoded = encoded[len(chunk):]
+		more := 0
+		if len(encoded) > 0 {
+			more = 1
+		}
+		keys := fmt.Sprintf("m=%d", more)
+		if first {
+			keys = control + "," + keys
+		}
+		if _, err := fmt.Fprintf(w, "\033_G%s;%s\033\\", keys, chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import "fmt"
+
+// maxJumpList bounds the jump history like vim's jumplist.
+const maxJumpList = 100
+
+// recordJump remembers the current position before a jump (search hit,
+// go-to-page, outline, bookmark or external jump). Positions are stored as
+// PDF page numbers so they survive reloads that change textPages.
+func (d *DocumentViewer) recordJump() {
+	if len(d.textPages) == 0 {
+		return
+	}
+	current := d.textPages[d.currentPage]
+
+	// Jumping from the middle of the history discards the forward part
+	if d.jumpIdx < len(d.jumpList) {
+		d.jumpList = d.jumpList[:d.jumpIdx]
+	}
+	// Keep each page once, at its most recent position
+	for i := 0; i < len(d.jumpList); i++ {
+		if d.jumpList[i] == current {
+			d.jumpList = append(d.jumpList[:i], d.jumpList[i+1:]...)
+			i--
+		}
+	}
+	d.jumpList = append(d.jumpList, current)
+	if len(d.jumpList) > maxJumpList {
+		d.jumpList = d.jumpList[len(d.jumpList)-maxJumpList:]
+	}
+	d.jumpIdx = len(d.jumpList)
+}
+
+// jumpBack returns to the previous position in the jump history (Ctrl-O).
+func (d *DocumentViewer) jumpBack() {
+	if d.jumpIdx == 0 || len(d.jumpList) == 0 {
+		return
+	}
+	if d.jumpIdx >= len(d.jumpList) {
+		// Leaving the newest position: remember it so Ctrl-I can come back
+		current := d.textPages[d.currentPage]
+		if d.jumpList[len(d.jumpList)-1] != current {
+			d.jumpList = append(d.jumpList, current)
+		}
+		d.jumpIdx = len(d.jumpList) - 1
+		if d.jumpIdx == 0 {
+			return
+		}
+	}
+	d.jumpIdx--
+	d.jumpToPage(d.jumpList[d.jumpIdx] + 1)
+}
+
+// jumpForward moves forward again in the jump history (Ctrl-I / Tab).
+func (d *DocumentViewer) jumpForward() {
+	if d.jumpIdx >= len(d.jumpList)-1 {
+		return
+	}
+	d.jumpIdx++
+	d.jumpToPage(d.jumpList[d.jumpIdx] + 1)
+}
+
+// jumpIndicator returns the status bar tag showing the jump history depth.
+func (d *DocumentViewer) jumpIndicator() string {
+	if len(d.jumpList) == 0 {
+		return ""
+	}
+	if d.jumpIdx >= len(d.jumpList) {
+		return fmt.Sprintf(" [jumps:%d]", len(d.jumpList))
+	}
+	return fmt.Sprintf(" [jump:%d/%d]", d.jumpIdx+1, len(d.jumpList))
+}
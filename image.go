@@ -5,12 +5,7 @@ import (
 	"image"
 	"image/color"
 	"image/draw"
-	"image/png"
 	"math"
-	"os"
-	"path/filepath"
-
-	"github.com/blacktop/go-termimg"
 )
 
 func (d *DocumentViewer) renderPageImage(pageNum, maxWidth, maxHeight int) int {
@@ -23,11 +18,10 @@ func (d *DocumentViewer) renderPageImageAligned(pageNum, maxWidth, maxHeight int
 	}
 
 	termType := d.detectTerminalType()
-	imagePath, actualHeight, imageWidthInChars, actualPixelWidth, actualPixelHeight, err := d.savePageAsImage(pageNum, maxWidth, maxHeight, termType)
+	img, key, actualHeight, imageWidthInChars, err := d.pageViewImage(pageNum, maxWidth, maxHeight)
 	if err != nil {
 		return 0
 	}
-	defer os.Remove(imagePath)
 
 	var horizontalOffset int
 	switch align {
@@ -42,100 +36,34 @@ func (d *DocumentViewer) renderPageImageAligned(pageNum, maxWidth, maxHeight int
 		horizontalOffset = 0
 	}
 
-	return d.renderWithTermImg(imagePath, actualHeight, horizontalOffset, imageWidthInChars, actualPixelWidth, actualPixelHeight, termType)
+	b := img.Bounds()
+	d.addPageArea(pageNum, image.Rect(0, 0, b.Dx(), b.Dy()), image.Pt(d.viewX, d.viewY), maxWidth, maxHeight)
+	return d.printPageImage(img, key, actualHeight, horizontalOffset, imageWidthInChars, termType)
 }
 
-func (d *DocumentViewer) savePageAsImage(pageNum, termWidth, termHeight int, termType string) (string, int, int, int, int, error) {
-	if err := os.MkdirAll(d.tempDir, 0o755); err != nil {
-		return "", 0, 0, 0, 0, err
-	}
-
+// pageViewImage renders the part of a page shown in a termWidth x termHeight
+// area. It also returns the cache key of the page image it is cut from (nil
+// when link hints are painted on it) and the lines and columns it takes.
+func (d *DocumentViewer) pageViewImage(pageNum, termWidth, termHeight int) (image.Image, *renderKey, int, int, error) {
 	pixelsPerChar, pixelsPerLine := d.getTerminalCellSize()
 
-	// Calculate target pixel dimensions based on terminal size
-	horizontalPadding := 4
-	verticalPadding := 3
-	effectiveWidth := termWidth - horizontalPadding
-	effectiveHeight := termHeight - verticalPadding
-
-	// Apply user scale factor
-	scale := d.scaleFactor
-	if scale == 0 {
-		scale = 1.0
-	}
-
-	targetPixelWidth := int(float64(effectiveWidth) * pixelsPerChar * scale)
-	targetPixelHeight := int(float64(effectiveHeight) * pixelsPerLine * scale)
-
-	// Get page dimensions at 72 DPI to calculate proper render DPI
-	testImg, err := d.doc.ImageDPI(pageNum, 72.0)
+	dpi, err := d.pageDPI(pageNum, termWidth, termHeight)
 	if err != nil {
-		return "", 0, 0, 0, 0, err
-	}
-	testBounds := testImg.Bounds()
-	pageWidthAt72 := testBounds.Dx()
-	pageHeightAt72 := testBounds.Dy()
-	aspectRatio := float64(pageHeightAt72) / float64(pageWidthAt72)
-
-	// Calculate final dimensions based on fit mode
-	var finalWidth, finalHeight int
-	switch d.fitMode {
-	case "height":
-		finalHeight = targetPixelHeight
-		finalWidth = int(float64(finalHeight) / aspectRatio)
-		if finalWidth > targetPixelWidth {
-			finalWidth = targetPixelWidth
-			finalHeight = int(float64(finalWidth) * aspectRatio)
-		}
-	case "width":
-		finalWidth = targetPixelWidth
-		finalHeight = int(float64(finalWidth) * aspectRatio)
-	default: // "auto"
-		finalWidth = targetPixelWidth
-		finalHeight = int(float64(finalWidth) * aspectRatio)
-		if finalHeight > targetPixelHeight {
-			finalHeight = targetPixelHeight
-			finalWidth = int(float64(finalHeight) / aspectRatio)
-		}
-	}
-
-	// Calculate DPI needed to render at exactly the right size
-	dpiForWidth := float64(finalWidth) / float64(pageWidthAt72) * 72.0
-	dpiForHeight := float64(finalHeight) / float64(pageHeightAt72) * 72.0
-	dpi := dpiForWidth
-	if dpiForHeight < dpi {
-		dpi = dpiForHeight
-	}
-
-	// Clamp DPI to reasonable range
-	// Sixel terminals (Foot) are slower, so use lower max DPI for better performance
-	if dpi < 36 {
-		dpi = 36
-	}
-	maxDPI := 300.0
-	if termType != "kitty" {
-		// Sixel terminals: reduce max DPI significantly for faster rendering
-		// 100 DPI is still very readable while being much faster to encode
-		maxDPI = 100.0
-	}
-	if dpi > maxDPI {
-		dpi = maxDPI
+		return nil, nil, 0, 0, err
 	}
 
-	// Render at calculated DPI - no resizing needed
-	img, err := d.doc.ImageDPI(pageNum, dpi)
+	// Render at calculated DPI (dark mode applied) - no resizing needed
+	d.renderSize = [2]int{termWidth, termHeight}
+	pageImg, err := d.pageImage(pageNum, dpi)
 	if err != nil {
-		return "", 0, 0, 0, 0, err
+		return nil, nil, 0, 0, err
 	}
+	finalImg, key := d.decoratePageImage(pageNum, pageImg, dpi)
 
-	// Apply dark mode
-	var finalImg image.Image = img
-	switch d.darkMode {
-	case "smart":
-		finalImg = smartInvert(img)
-	case "invert":
-		finalImg = simpleInvert(img)
-	}
+	// Pages larger than the screen show only the scrolled-to part
+	viewW := int(float64(termWidth-1) * pixelsPerChar)
+	viewH := int(float64(termHeight) * pixelsPerLine)
+	finalImg = d.cropToViewport(finalImg, viewW, viewH)
 
 	bounds := finalImg.Bounds()
 	actualWidth := bounds.Dx()
@@ -148,33 +76,21 @@ func (d *DocumentViewer) savePageAsImage(pageNum, termWidth, termHeight int, ter
 
 	imageWidthInChars := int(float64(actualWidth)/pixelsPerChar) + 1
 
-	filename := fmt.Sprintf("page_%d.png", pageNum)
-	imagePath := filepath.Join(d.tempDir, filename)
-
-	file, err := os.Create(imagePath)
-	if err != nil {
-		return "", 0, 0, 0, 0, err
-	}
-	defer file.Close()
-
-	err = png.Encode(file, finalImg)
-	if err != nil {
-		os.Remove(imagePath)
-		return "", 0, 0, 0, 0, err
-	}
-
-	return imagePath, actualLines, imageWidthInChars, actualWidth, actualHeight, nil
+	return finalImg, key, actualLines, imageWidthInChars, nil
 }
 
-// renderPageToImage renders a page to an in-memory image at the given terminal dimensions.
-func (d *DocumentViewer) renderPageToImage(pageNum, termWidth, termHeight int, termType string) (image.Image, error) {
+// pageDPI returns the resolution at which a page fills the terminal area
+// according to the fit mode and zoom, clamped to what the terminal handles.
+func (d *DocumentViewer) pageDPI(pageNum, termWidth, termHeight int) (float64, error) {
 	pixelsPerChar, pixelsPerLine := d.getTerminalCellSize()
 
+	// Calculate target pixel dimensions based on terminal size
 	horizontalPadding := 4
 	verticalPadding := 3
 	effectiveWidth := termWidth - horizontalPadding
 	effectiveHeight := termHeight - verticalPadding
 
+	// Apply user scale factor
 	scale := d.scaleFactor
 	if scale == 0 {
 		scale = 1.0
@@ -183,15 +99,19 @@ func (d *DocumentViewer) renderPageToImage(pageNum, termWidth, termHeight int, t
 	targetPixelWidth := int(float64(effectiveWidth) * pixelsPerChar * scale)
 	targetPixelHeight := int(float64(effectiveHeight) * pixelsPerLine * scale)
 
-	testImg, err := d.doc.ImageDPI(pageNum, 72.0)
+	// Page size in points is the pixel size at 72 DPI
+	bounds, err := d.doc.Bound(pageNum)
 	if err != nil {
-		return nil, err
+		return 0, err
+	}
+	pageWidthAt72 := bounds.Dx()
+	pageHeightAt72 := bounds.Dy()
+	if pageWidthAt72 <= 0 || pageHeightAt72 <= 0 {
+		return 0, fmt.Errorf("page %d has an empty size", pageNum+1)
 	}
-	testBounds := testImg.Bounds()
-	pageWidthAt72 := testBounds.Dx()
-	pageHeightAt72 := testBounds.Dy()
 	aspectRatio := float64(pageHeightAt72) / float64(pageWidthAt72)
 
+	// Calculate final dimensions based on fit mode
 	var finalWidth, finalHeight int
 	switch d.fitMode {
 	case "height":
@@ -204,7 +124,7 @@ func (d *DocumentViewer) renderPageToImage(pageNum, termWidth, termHeight int, t
 	case "width":
 		finalWidth = targetPixelWidth
 		finalHeight = int(float64(finalWidth) * aspectRatio)
-	default:
+	default: // "auto"
 		finalWidth = targetPixelWidth
 		finalHeight = int(float64(finalWidth) * aspectRatio)
 		if finalHeight > targetPixelHeight {
@@ -213,6 +133,7 @@ func (d *DocumentViewer) renderPageToImage(pageNum, termWidth, termHeight int, t
 		}
 	}
 
+	// Calculate DPI needed to render at exactly the right size
 	dpiForWidth := float64(finalWidth) / float64(pageWidthAt72) * 72.0
 	dpiForHeight := float64(finalHeight) / float64(pageHeightAt72) * 72.0
 	dpi := dpiForWidth
@@ -220,28 +141,31 @@ func (d *DocumentViewer) renderPageToImage(pageNum, termWidth, termHeight int, t
 		dpi = dpiForHeight
 	}
 
-	if dpi < 36 {
+	// Clamp DPI to reasonable range. The DPI fits the page to the terminal's
+	// pixels, so Sixel output is no larger than the screen either.
+	if dpi < 36 && d.cellRenderMode() == "" {
+		// Text cells are much coarser than that anyway
 		dpi = 36
 	}
-	maxDPI := 300.0
-	if termType != "kitty" {
-		maxDPI = 100.0
-	}
-	if dpi > maxDPI {
-		dpi = maxDPI
+	if dpi > 300 {
+		dpi = 300
 	}
+	return dpi, nil
+}
 
-	img, err := d.doc.ImageDPI(pageNum, dpi)
+// renderPageToImage renders a page to an in-memory image at the given terminal dimensions.
+func (d *DocumentViewer) renderPageToImage(pageNum, termWidth, termHeight int) (image.Image, error) {
+	dpi, err := d.pageDPI(pageNum, termWidth, termHeight)
 	if err != nil {
 		return nil, err
 	}
 
-	switch d.darkMode {
-	case "smart":
-		return smartInvert(img), nil
-	case "invert":
-		return simpleInvert(img), nil
+	d.renderSize = [2]int{termWidth, termHeight}
+	img, err := d.pageImage(pageNum, dpi)
+	if err != nil {
+		return nil, err
 	}
+	img, _ = d.decoratePageImage(pageNum, img, dpi)
 	return img, nil
 }
 
@@ -254,7 +178,6 @@ func (d *DocumentViewer) renderDualComposite(page1, page2 int, hasPage2 bool, te
 	}
 
 	termType := d.detectTerminalType()
-	pixelsPerChar, pixelsPerLine := d.getTerminalCellSize()
 
 	var img1W, img2W int
 	var img1H, img2H int
@@ -273,17 +196,16 @@ func (d *DocumentViewer) renderDualComposite(page1, page2 int, hasPage2 bool, te
 		img2H = termHeight
 	}
 
-	page1Img, err := d.renderPageToImage(page1, img1W, img1H, termType)
-	if err != nil {
-		return 0
-	}
-
+	// Both pages are requested before giving up, so a pending render of
+	// the first does not hold back the second
+	page1Img, err1 := d.renderPageToImage(page1, img1W, img1H)
 	var page2Img image.Image
+	var err2 error
 	if hasPage2 {
-		page2Img, err = d.renderPageToImage(page2, img2W, img2H, termType)
-		if err != nil {
-			return 0
-		}
+		page2Img, err2 = d.renderPageToImage(page2, img2W, img2H)
+	}
+	if err1 != nil || err2 != nil {
+		return 0
 	}
 
 	b1 := page1Img.Bounds()
@@ -314,12 +236,14 @@ func (d *DocumentViewer) renderDualComposite(page1, page2 int, hasPage2 bool, te
 		// Center page 1 horizontally
 		x1 := (compositeW - b1.Dx()) / 2
 		draw.Draw(composite, image.Rect(x1, 0, x1+b1.Dx(), b1.Dy()), page1Img, b1.Min, draw.Over)
+		d.addPageArea(page1, image.Rect(x1, 0, x1+b1.Dx(), b1.Dy()), image.Point{}, img1W, img1H)
 
 		if page2Img != nil {
 			b2 := page2Img.Bounds()
 			x2 := (compositeW - b2.Dx()) / 2
 			y2 := b1.Dy() + gap
 			draw.Draw(composite, image.Rect(x2, y2, x2+b2.Dx(), y2+b2.Dy()), page2Img, b2.Min, draw.Over)
+			d.addPageArea(page2, image.Rect(x2, y2, x2+b2.Dx(), y2+b2.Dy()), image.Point{}, img2W, img2H)
 		}
 	} else {
 		// Horizontal
@@ -343,31 +267,26 @@ func (d *DocumentViewer) renderDualComposite(page1, page2 int, hasPage2 bool, te
 		// Center page 1 vertically
 		y1 := (compositeH - b1.Dy()) / 2
 		draw.Draw(composite, image.Rect(0, y1, b1.Dx(), y1+b1.Dy()), page1Img, b1.Min, draw.Over)
+		d.addPageArea(page1, image.Rect(0, y1, b1.Dx(), y1+b1.Dy()), image.Point{}, img1W, img1H)
 
 		if page2Img != nil {
 			b2 := page2Img.Bounds()
 			x2 := b1.Dx() + gap
 			y2 := (compositeH - b2.Dy()) / 2
 			draw.Draw(composite, image.Rect(x2, y2, x2+b2.Dx(), y2+b2.Dy()), page2Img, b2.Min, draw.Over)
+			d.addPageArea(page2, image.Rect(x2, y2, x2+b2.Dx(), y2+b2.Dy()), image.Point{}, img2W, img2H)
 		}
 	}
 
-	// Save composite
-	if err := os.MkdirAll(d.tempDir, 0o755); err != nil {
-		return 0
-	}
-	imagePath := filepath.Join(d.tempDir, "dual.png")
-	file, err := os.Create(imagePath)
-	if err != nil {
-		return 0
-	}
-	if err := png.Encode(file, composite); err != nil {
-		file.Close()
-		os.Remove(imagePath)
-		return 0
-	}
-	file.Close()
-	defer os.Remove(imagePath)
+	return d.printComposite(composite, termWidth, termHeight, termType)
+}
+
+// printComposite prints a composited image centered in the terminal area.
+// Returns the number of lines used (0 on failure).
+func (d *DocumentViewer) printComposite(composite image.Image, termWidth, termHeight int, termType string) int {
+	pixelsPerChar, pixelsPerLine := d.getTerminalCellSize()
+	compositeW := composite.Bounds().Dx()
+	compositeH := composite.Bounds().Dy()
 
 	actualLines := int(float64(compositeH)/pixelsPerLine) + 1
 	if actualLines > termHeight {
@@ -380,38 +299,47 @@ func (d *DocumentViewer) renderDualComposite(page1, page2 int, hasPage2 bool, te
 		horizontalOffset = 0
 	}
 
-	return d.renderWithTermImg(imagePath, actualLines, horizontalOffset, imageWidthInChars, compositeW, compositeH, termType)
+	return d.printPageImage(composite, nil, actualLines, horizontalOffset, imageWidthInChars, termType)
 }
 
-func (d *DocumentViewer) renderWithTermImg(imagePath string, estimatedLines int, horizontalOffset int, widthChars int, pixelWidth int, pixelHeight int, termType string) int {
+// printPageImage prints an image at the cursor, moved right by
+// horizontalOffset columns. key is the cache key of the page image img is
+// cut from, or nil. Returns the number of lines used (0 on failure).
+func (d *DocumentViewer) printPageImage(img image.Image, key *renderKey, estimatedLines int, horizontalOffset int, widthChars int, termType string) int {
 	if horizontalOffset > 0 {
 		fmt.Printf("\033[%dC", horizontalOffset) // Move cursor right
 	}
 
-	// Use termimg fluent API to control size in terminal cells
-	img, err := termimg.Open(imagePath)
-	if err != nil {
-		return 0
-	}
-
-	// Choose rendering strategy based on terminal type:
-	// - Kitty uses native graphics protocol with character-based dimensions
-	// - Sixel terminals need pixel-based dimensions for proper scaling
-	if termType == "kitty" {
-		// Kitty: use character-based dimensions with ScaleNone
-		err = img.Width(widthChars).Height(estimatedLines).Scale(termimg.ScaleNone).Print()
+	var err error
+	protocol := d.graphicsProtocol(termType)
+	if mode := d.cellRenderMode(); mode != "" {
+		err = printCells(img, mode, horizontalOffset, d.darkMode)
+	} else if protocol == "kitty" {
+		// Cached pages are uploaded once and placed again from then on
+		err = d.placeKittyPage(key, img, widthChars, estimatedLines, horizontalOffset)
 	} else {
-		// Sixel terminals (Foot, xterm, etc.): use pixel-based dimensions with ScaleFit
-		err = img.WidthPixels(pixelWidth).HeightPixels(pixelHeight).Scale(termimg.ScaleFit).Print()
+		err = printImage(img, widthChars, estimatedLines, protocol)
 	}
-
 	if err != nil {
+		d.screen.pages = nil
 		return 0
 	}
+	d.screen.col = horizontalOffset + 1
 
 	return estimatedLines
 }
 
+// applyDarkMode returns the image with the given dark mode applied.
+func applyDarkMode(img image.Image, darkMode string) image.Image {
+	switch darkMode {
+	case "smart":
+		return smartInvert(img)
+	case "invert":
+		return simpleInvert(img)
+	}
+	return img
+}
+
 // smartInvert inverts lightness while preserving hue and saturation.
 // White backgrounds become black, black text becomes white, colors keep their hue.
 func smartInvert(src image.Image) image.Image {
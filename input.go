@@ -0,0 +1,446 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Terminal input is decoded into key events by one decoder shared by the
+// viewer and the file picker: UTF-8 characters, control keys, the CSI and
+// SS3 sequences of special keys (xterm style, with modifiers), SGR mouse
+// reports and, when the terminal supports it, the Kitty keyboard protocol,
+// which sends Esc and modified keys unambiguously. A lone ESC byte is the
+// Escape key once escTimeout passes without the rest of a sequence. The
+// terminal's answer to the cell size query (CSI 16 t) comes in with the
+// keys, so it is decoded as an event too; answers to the other queries of
+// the startup probe that arrive too late for it are skipped.
+
+// escTimeout is how long an ESC byte waits for the rest of an escape
+// sequence before it counts as the Escape key.
+const escTimeout = 50 * time.Millisecond
+
+// keyName identifies a key event.
+type keyName int
+
+const (
+	keyNone keyName = iota // undecodable input, ignored
+	keyRune                // a character, in keyEvent.r
+	keyEnter
+	keyTab
+	keyBackspace
+	keyEscape
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyHome
+	keyEnd
+	keyPageUp
+	keyPageDown
+	keyInsert
+	keyDelete
+	keyMouse    // a mouse report, in keyEvent.mouse
+	keyCellSize // the terminal's cell size in pixels, in keyEvent.cellWidth/cellHeight
+	keyF1       // keyF1+n-1 is Fn, up to F12
+)
+
+// keyMod is a set of modifiers, in the bit order of xterm's modifier
+// parameter minus one.
+type keyMod uint8
+
+const (
+	modShift keyMod = 1 << iota
+	modAlt
+	modCtrl
+)
+
+// keyEvent is one decoded key press or mouse report.
+type keyEvent struct {
+	key   keyName
+	r     rune // for keyRune; lower case for Ctrl+letter
+	mod   keyMod
+	mouse mouseEvent
+
+	cellWidth, cellHeight int // for keyCellSize
+}
+
+// mouseEvent is an SGR mouse report.
+type mouseEvent struct {
+	button  int // 0 left, 1 middle, 2 right, 64/65 wheel up/down
+	x, y    int // cell, 1-based
+	release bool
+	motion  bool
+}
+
+// char returns the key as a single character, the way menus and overlays
+// read keys: the character typed, control codes for Enter, Tab, Backspace,
+// Escape and Ctrl+letter, and the vim keys for arrows, PageUp/PageDown and
+// Home/End. Alt+character and other keys give 0.
+func (ev keyEvent) char() rune {
+	switch ev.key {
+	case keyRune:
+		if ev.mod&modAlt != 0 {
+			return 0
+		}
+		if ev.mod&modCtrl != 0 && ev.r >= 'a' && ev.r <= 'z' {
+			return ev.r & 0x1f
+		}
+		return ev.r
+	case keyEnter:
+		return 13
+	case keyTab:
+		return 9
+	case keyBackspace:
+		return 127
+	case keyEscape:
+		return 27
+	case keyUp:
+		return 'k'
+	case keyDown:
+		return 'j'
+	case keyLeft:
+		return 'h'
+	case keyRight:
+		return 'l'
+	case keyPageUp:
+		return 'K'
+	case keyPageDown:
+		return 'J'
+	case keyHome:
+		return 'g'
+	case keyEnd:
+		return 'G'
+	}
+	return 0
+}
+
+// text returns the character typed for text input, or 0 if ev is not a
+// plain (possibly shifted) character.
+func (ev keyEvent) text() rune {
+	if ev.key != keyRune || ev.mod&(modCtrl|modAlt) != 0 || ev.r < 32 {
+		return 0
+	}
+	return ev.r
+}
+
+// keyReader decodes key events from stdin. It polls rather than blocking
+// in read, so a goroutine using it can stop without taking input meant for
+// whoever reads the terminal next.
+type keyReader struct {
+	fd       int
+	buf      []byte // input read but not decoded yet
+	inString bool   // skipping the rest of a terminal string cut off by escTimeout
+}
+
+func newKeyReader() *keyReader {
+	return &keyReader{fd: int(os.Stdin.Fd())}
+}
+
+// next returns the next key event, waiting at most timeout for input.
+func (r *keyReader) next(timeout time.Duration) (keyEvent, bool) {
+	for {
+		if len(r.buf) == 0 && !r.fill(timeout) {
+			r.inString = false
+			return keyEvent{}, false
+		}
+		if r.inString {
+			end := stringEnd(r.buf)
+			if end < 0 {
+				r.buf = r.buf[:0]
+				continue
+			}
+			r.buf = r.buf[end:]
+			r.inString = false
+			continue
+		}
+		ev, n := decodeKey(r.buf, false)
+		if n == 0 {
+			// Incomplete sequence or character: the rest normally follows
+			// right away
+			if r.fill(escTimeout) {
+				continue
+			}
+			ev, n = decodeKey(r.buf, true)
+			// Skip the rest of a terminal string that was cut off too
+			r.inString = n > 2 && isStringStart(r.buf)
+		}
+		r.buf = r.buf[n:]
+		if ev.key != keyNone {
+			return ev, true
+		}
+	}
+}
+
+// fill appends the input available within timeout to the buffer.
+func (r *keyReader) fill(timeout time.Duration) bool {
+	if !waitForInput(r.fd, timeout) {
+		return false
+	}
+	buf := make([]byte, 256)
+	n, _ := os.Stdin.Read(buf)
+	if n <= 0 {
+		// Closed: don't spin on a descriptor that is always readable
+		time.Sleep(timeout)
+		return false
+	}
+	r.buf = append(r.buf, buf[:n]...)
+	return true
+}
+
+// decodeKey decodes the event at the start of b and returns it with the
+// number of bytes it took. It returns 0 bytes if b holds only the start of
+// an event, unless force is set, in which case what is there is decoded as
+// well as possible.
+func decodeKey(b []byte, force bool) (keyEvent, int) {
+	c := b[0]
+	switch {
+	case c == 27:
+		if len(b) == 1 {
+			if !force {
+				return keyEvent{}, 0
+			}
+			return keyEvent{key: keyEscape}, 1
+		}
+		switch b[1] {
+		case '[':
+			return decodeCSI(b, force)
+		case 'O':
+			if len(b) > 2 {
+				return ss3Key(b[2]), 3
+			}
+			if !force {
+				return keyEvent{}, 0
+			}
+		case 27:
+			return keyEvent{key: keyEscape}, 1
+		case 'P', '_', ']', 'X', '^':
+			// A DCS, APC, OSC, SOS or PM string is not a key but a late
+			// answer to a query: skip it
+			if end := stringEnd(b[2:]); end >= 0 {
+				return keyEvent{}, 2 + end
+			}
+			if !force {
+				return keyEvent{}, 0
+			}
+			if len(b) > 2 {
+				return keyEvent{}, len(b)
+			}
+		}
+		// ESC before a key is Alt
+		ev, n := decodeKey(b[1:], force)
+		if n == 0 {
+			return ev, 0
+		}
+		ev.mod |= modAlt
+		return ev, n + 1
+	case c == 13 || c == 10:
+		return keyEvent{key: keyEnter}, 1
+	case c == 9:
+		return keyEvent{key: keyTab}, 1
+	case c == 127 || c == 8:
+		return keyEvent{key: keyBackspace}, 1
+	case c == 0:
+		return keyEvent{key: keyRune, r: ' ', mod: modCtrl}, 1
+	case c < 27:
+		return keyEvent{key: keyRune, r: rune('a' + c - 1), mod: modCtrl}, 1
+	case c < 32:
+		return keyEvent{}, 1
+	}
+
+	if !utf8.FullRune(b) {
+		if !force {
+			return keyEvent{}, 0
+		}
+		return keyEvent{}, len(b)
+	}
+	r, n := utf8.DecodeRune(b)
+	if r == utf8.RuneError {
+		return keyEvent{}, n
+	}
+	return keyEvent{key: keyRune, r: r}, n
+}
+
+// isStringStart reports whether b starts a DCS, APC, OSC, SOS or PM string.
+func isStringStart(b []byte) bool {
+	return len(b) >= 2 && b[0] == 27 && strings.IndexByte("P_]X^", b[1]) >= 0
+}
+
+// stringEnd returns the length of the string contents at the start of b up
+// to and including the terminator (ST, or BEL as xterm allows for OSC), or
+// -1 if b does not hold the terminator yet.
+func stringEnd(b []byte) int {
+	for i, c := range b {
+		switch {
+		case c == 7:
+			return i + 1
+		case c == 27 && i+1 < len(b) && b[i+1] == '\\':
+			return i + 2
+		}
+	}
+	return -1
+}
+
+// decodeCSI decodes a control sequence: ESC [, parameter and intermediate
+// bytes, and a final byte.
+func decodeCSI(b []byte, force bool) (keyEvent, int) {
+	i := 2
+	for i < len(b) && b[i] >= 0x20 && b[i] <= 0x3f {
+		i++
+	}
+	if i == len(b) {
+		switch {
+		case !force:
+			return keyEvent{}, 0
+		case i == 2:
+			return keyEvent{key: keyRune, r: '[', mod: modAlt}, 2
+		}
+		return keyEvent{}, i
+	}
+	if b[i] < 0x40 || b[i] > 0x7e {
+		// Not a sequence after all: drop what was read of it
+		return keyEvent{}, i
+	}
+	return csiKey(string(b[2:i]), b[i]), i + 1
+}
+
+// csiKey returns the key for a control sequence with the given parameters
+// and final byte.
+func csiKey(params string, final byte) keyEvent {
+	if strings.HasPrefix(params, "<") && (final == 'M' || final == 'm') {
+		return sgrMouse(params[1:], final == 'm')
+	}
+	fields := strings.Split(params, ";")
+	// num returns parameter i, ignoring Kitty's sub-parameters
+	num := func(i, def int) int {
+		if i >= len(fields) {
+			return def
+		}
+		field, _, _ := strings.Cut(fields[i], ":")
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return def
+		}
+		return n
+	}
+	mod := keyMod(max(num(1, 1)-1, 0)) & (modShift | modAlt | modCtrl)
+	ev := keyEvent{mod: mod}
+
+	switch final {
+	case 'A':
+		ev.key = keyUp
+	case 'B':
+		ev.key = keyDown
+	case 'C':
+		ev.key = keyRight
+	case 'D':
+		ev.key = keyLeft
+	case 'H':
+		ev.key = keyHome
+	case 'F':
+		ev.key = keyEnd
+	case 'P', 'Q', 'R', 'S':
+		ev.key = keyF1 + keyName(final-'P')
+	case 'Z':
+		ev.key, ev.mod = keyTab, mod|modShift
+	case '~':
+		switch n := num(0, 0); {
+		case n == 1 || n == 7:
+			ev.key = keyHome
+		case n == 4 || n == 8:
+			ev.key = keyEnd
+		case n == 2:
+			ev.key = keyInsert
+		case n == 3:
+			ev.key = keyDelete
+		case n == 5:
+			ev.key = keyPageUp
+		case n == 6:
+			ev.key = keyPageDown
+		case n >= 11 && n <= 15:
+			ev.key = keyF1 + keyName(n-11)
+		case n >= 17 && n <= 21:
+			ev.key = keyF1 + 5 + keyName(n-17)
+		case n == 23 || n == 24:
+			ev.key = keyF1 + 10 + keyName(n-23)
+		}
+	case 't':
+		// Answer to CSI 16 t: CSI 6 ; height ; width t
+		if num(0, 0) == 6 && num(1, 0) > 0 && num(2, 0) > 0 {
+			return keyEvent{key: keyCellSize, cellWidth: num(2, 0), cellHeight: num(1, 0)}
+		}
+	case 'u':
+		// Kitty keyboard protocol: Unicode code point of the key
+		switch code := num(0, 0); {
+		case code == 13:
+			ev.key = keyEnter
+		case code == 9:
+			ev.key = keyTab
+		case code == 127:
+			ev.key = keyBackspace
+		case code == 27:
+			ev.key = keyEscape
+		case code >= 32 && code < 0xE000: // above: private use (functional keys)
+			ev.key, ev.r = keyRune, rune(code)
+		}
+	}
+	return ev
+}
+
+// ss3Key returns the key for ESC O and the given byte, which some
+// terminals send for arrows, Home/End and F1-F4.
+func ss3Key(c byte) keyEvent {
+	switch c {
+	case 'A':
+		return keyEvent{key: keyUp}
+	case 'B':
+		return keyEvent{key: keyDown}
+	case 'C':
+		return keyEvent{key: keyRight}
+	case 'D':
+		return keyEvent{key: keyLeft}
+	case 'H':
+		return keyEvent{key: keyHome}
+	case 'F':
+		return keyEvent{key: keyEnd}
+	case 'P', 'Q', 'R', 'S':
+		return keyEvent{key: keyF1 + keyName(c-'P')}
+	}
+	return keyEvent{}
+}
+
+// sgrMouse decodes the parameters of an SGR mouse report: button;x;y.
+func sgrMouse(params string, release bool) keyEvent {
+	fields := strings.Split(params, ";")
+	if len(fields) != 3 {
+		return keyEvent{}
+	}
+	var v [3]int
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return keyEvent{}
+		}
+		v[i] = n
+	}
+	b := v[0]
+	ev := keyEvent{key: keyMouse, mouse: mouseEvent{
+		button:  b &^ (4 | 8 | 16 | 32),
+		x:       v[1],
+		y:       v[2],
+		release: release,
+		motion:  b&32 != 0,
+	}}
+	if b&4 != 0 {
+		ev.mod |= modShift
+	}
+	if b&8 != 0 {
+		ev.mod |= modAlt
+	}
+	if b&16 != 0 {
+		ev.mod |= modCtrl
+	}
+	return ev
+}
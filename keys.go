@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// maxCount caps numeric prefixes so a stray key held down cannot overflow.
+const maxCount = 99999
+
+// handleKey runs a key through the vim-style sequence parser in front of
+// handleInput: numeric counts ("25j", "50%"), multi-key commands ("gg") and
+// counted motions ("12G"). It returns the same action codes as handleInput.
+// While a sequence is incomplete it returns 0 and keeps it in pendingKeys.
+// No key waits for a possible second one: "g" opens the go-to-page prompt
+// at once, and a second g there goes to the first page.
+func (d *DocumentViewer) handleKey(ev keyEvent) int {
+	if ev.key == keyHome { // like gg
+		d.pendingKeys = ""
+		d.goToPageNumber(0, 1)
+		return 0
+	}
+	c := d.viewerKey(ev)
+	if c == 0 {
+		return 0
+	}
+	pending := d.pendingKeys
+	count, prefix := splitCount(pending)
+
+	switch {
+	case c == 27: // ESC cancels a pending sequence, or else a running search
+		if d.pendingKeys != "" {
+			d.pendingKeys = ""
+			return 0
+		}
+		if d.searchRunning() {
+			d.cancelSearch()
+			d.statusMessage = fmt.Sprintf("Search cancelled after %d/%d pages", d.searchScanned, len(d.textPages))
+			return 0
+		}
+	case prefix == "" && (c >= '1' && c <= '9' || c == '0' && count > 0):
+		if count*10+int(c-'0') <= maxCount {
+			d.pendingKeys += string(c)
+		}
+		return 0
+	}
+	d.pendingKeys = ""
+
+	if prefix == "g" {
+		if c == 'g' { // gg, Ngg
+			d.goToPageNumber(count, 1)
+		}
+		// Anything else after g cancels it, like vim
+		return 0
+	}
+
+	n := count
+	if n == 0 {
+		n = 1
+	}
+	switch c {
+	case 'g':
+		if count == 0 {
+			return d.handleInput(c)
+		}
+		d.pendingKeys = pending + "g"
+		return 0
+	case 'G': // G: last page, NG: page N
+		d.goToPageNumber(count, d.doc.NumPage())
+	case '%':
+		if count > 0 && count <= 100 {
+			d.goToPageNumber((count*d.doc.NumPage()+99)/100, 1)
+		}
+	case 'n':
+		d.nextSearchHit(n)
+	case 'N':
+		d.prevSearchHit(n)
+	case 'j', ' ', 'k', 'J', 'K':
+		for i := 0; i < n; i++ {
+			d.handleInput(c)
+		}
+	default:
+		// Counts only apply to motions; other keys run once
+		return d.handleInput(c)
+	}
+	return 0
+}
+
+// viewerKey returns the key binding a key event triggers: the character
+// typed, or for special keys the key with the same meaning. Arrows turn
+// pages like j/k, with Shift like J/K; PageUp/PageDown go a screen in
+// continuous and two pages in dual-page mode (J/K), a page otherwise.
+func (d *DocumentViewer) viewerKey(ev keyEvent) rune {
+	shifted := ev.mod&modShift != 0
+	switch ev.key {
+	case keyDown, keyRight:
+		if shifted {
+			return 'J'
+		}
+		return 'j'
+	case keyUp, keyLeft:
+		if shifted {
+			return 'K'
+		}
+		return 'k'
+	case keyPageDown:
+		if d.continuous || d.dualPageMode != "" {
+			return 'J'
+		}
+		return 'j'
+	case keyPageUp:
+		if d.continuous || d.dualPageMode != "" {
+			return 'K'
+		}
+		return 'k'
+	case keyF1:
+		return '?'
+	}
+	return ev.char()
+}
+
+// splitCount splits a pending sequence like "12g" into its count and the
+// keys after it. The count is 0 when there is none.
+func splitCount(keys string) (int, string) {
+	i := 0
+	for i < len(keys) && keys[i] >= '0' && keys[i] <= '9' {
+		i++
+	}
+	count, _ := strconv.Atoi(keys[:i])
+	return count, keys[i:]
+}
+
+// goToPageNumber jumps to physical page count (1-indexed), or to fallback
+// when no count was given, recording the jump.
+func (d *DocumentViewer) goToPageNumber(count, fallback int) {
+	page := fallback
+	if count > 0 {
+		page = count
+	}
+	if page > d.doc.NumPage() {
+		page = d.doc.NumPage()
+	}
+	d.recordJump()
+	d.jumpToPage(page)
+}
+
+// drawPendingKeys shows the pending key sequence at the right end of the
+// status bar, like vim's showcmd.
+func (d *DocumentViewer) drawPendingKeys() {
+	termWidth, termHeight := d.getTerminalSize()
+	text := " " + d.pendingKeys + " "
+	col := termWidth - len(text) - 1
+	if col < 1 {
+		col = 1
+	}
+	fmt.Printf("\033[%d;%dH\033[7m%s\033[0m", termHeight, col, text)
+}
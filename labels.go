@@ -0,0 +1,133 @@
+package main
+
+/*
+#include <stddef.h>
+#include "fitzguard.h"
+
+extern void *pdf_specifics(void *ctx, void *doc);
+extern void *pdf_trailer(void *ctx, void *pdf);
+extern void *pdf_dict_getp(void *ctx, void *obj, const char *path);
+extern void pdf_page_label(void *ctx, void *pdf, int page, char *buf, size_t size);
+
+// dv_has_page_labels reports whether doc is a PDF with a /PageLabels tree.
+static int dv_has_page_labels(void *ctx, void *doc) {
+	void *pdf = pdf_specifics(ctx, doc);
+	int found = 0;
+	if (pdf == NULL)
+		return 0;
+	dv_try(ctx) {
+		found = pdf_dict_getp(ctx, pdf_trailer(ctx, pdf), "Root/PageLabels") != NULL;
+	}
+	dv_catch(ctx) {
+		fz_ignore_error(ctx);
+		return 0;
+	}
+	return found;
+}
+
+// dv_page_label looks the label of a page up in the /PageLabels tree,
+// without loading the page.
+static int dv_page_label(void *ctx, void *doc, int page, char *buf, int size) {
+	dv_try(ctx) {
+		pdf_page_label(ctx, pdf_specifics(ctx, doc), page, buf, size);
+	}
+	dv_catch(ctx) {
+		fz_ignore_error(ctx);
+		return dv_failed;
+	}
+	return 0;
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// loadPageLabels reads the printed page labels (the PDF /PageLabels number
+// tree). pageLabels stays nil when every label equals the physical number,
+// and for documents without the tree, which are not read page by page.
+func (d *DocumentViewer) loadPageLabels() {
+	d.pageLabels = nil
+	ctx, docPtr := fitzPointers(d.doc)
+	if C.dv_has_page_labels(ctx, docPtr) == 0 {
+		return
+	}
+	n := d.doc.NumPage()
+
+	labels := make([]string, n)
+	hasLabels := false
+	buf := make([]byte, 64)
+	for i := 0; i < n; i++ {
+		if C.dv_page_label(ctx, docPtr, C.int(i), (*C.char)(unsafe.Pointer(&buf[0])), C.int(len(buf))) != 0 {
+			continue
+		}
+		labels[i] = strings.TrimSpace(C.GoString((*C.char)(unsafe.Pointer(&buf[0]))))
+		if labels[i] != "" && labels[i] != strconv.Itoa(i+1) {
+			hasLabels = true
+		}
+	}
+	if hasLabels {
+		d.pageLabels = labels
+	}
+}
+
+// pageLabel returns the printed label of a PDF page ("xii", "A-3"), falling
+// back to the 1-indexed physical page number.
+func (d *DocumentViewer) pageLabel(pdfPage int) string {
+	if pdfPage >= 0 && pdfPage < len(d.pageLabels) && d.pageLabels[pdfPage] != "" {
+		return d.pageLabels[pdfPage]
+	}
+	return strconv.Itoa(pdfPage + 1)
+}
+
+// pagePosition formats the current page for the status bar: "xii (14/320)"
+// for documents with page labels, "14/320" otherwise. With two pages shown
+// it becomes "xii-xiii (14-15/320)".
+func (d *DocumentViewer) pagePosition(showSecond bool) string {
+	first := d.textPages[d.currentPage]
+	total := d.doc.NumPage()
+	if !showSecond || d.currentPage+1 >= len(d.textPages) {
+		if d.pageLabels == nil {
+			return fmt.Sprintf("%d/%d", first+1, total)
+		}
+		return fmt.Sprintf("%s (%d/%d)", d.pageLabel(first), first+1, total)
+	}
+	second := d.textPages[d.currentPage+1]
+	if d.pageLabels == nil {
+		return fmt.Sprintf("%d-%d/%d", first+1, second+1, total)
+	}
+	return fmt.Sprintf("%s-%s (%d-%d/%d)", d.pageLabel(first), d.pageLabel(second), first+1, second+1, total)
+}
+
+// resolvePageInput maps go-to-page input to a 0-indexed PDF page. A page
+// label ("xii", "A-3") wins over a physical number, since that is what a
+// printed reference means; a leading '#' forces the physical page.
+func (d *DocumentViewer) resolvePageInput(input string) (int, bool) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return 0, false
+	}
+
+	if !strings.HasPrefix(input, "#") && d.pageLabels != nil {
+		for i, label := range d.pageLabels {
+			if label == input {
+				return i, true
+			}
+		}
+		for i, label := range d.pageLabels {
+			if strings.EqualFold(label, input) {
+				return i, true
+			}
+		}
+	}
+
+	num, err := strconv.Atoi(strings.TrimPrefix(input, "#"))
+	if err != nil || num < 1 || num > d.doc.NumPage() {
+		return 0, false
+	}
+	return num - 1, true
+}
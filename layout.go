@@ -5,6 +5,20 @@ package main
 // It controls the page layout for reflowable documents (HTML, EPUB).
 // w = page width in points, h = page height in points, em = base font size in points.
 extern void fz_layout_document(void *ctx, void *doc, float w, float h, float em);
+
+#include "fitzguard.h"
+
+// dv_layout_document lays the document out with fz_layout_document.
+static int dv_layout_document(void *ctx, void *doc, float w, float h, float em) {
+	dv_try(ctx) {
+		fz_layout_document(ctx, doc, w, h, em);
+	}
+	dv_catch(ctx) {
+		fz_ignore_error(ctx);
+		return dv_failed;
+	}
+	return 0;
+}
 */
 import "C"
 
@@ -15,12 +29,27 @@ import (
 	"github.com/gen2brain/go-fitz"
 )
 
+// fitzPointers returns the fz_context and fz_document pointers wrapped by a
+// go-fitz Document, for calling MuPDF functions go-fitz does not expose.
+func fitzPointers(doc *fitz.Document) (unsafe.Pointer, unsafe.Pointer) {
+	v := reflect.ValueOf(doc).Elem()
+	ctx := unsafe.Pointer(v.Field(0).Pointer())
+	docPtr := unsafe.Pointer(v.Field(2).Pointer())
+	return ctx, docPtr
+}
+
 // layoutDocument calls MuPDF's fz_layout_document to control page layout
 // for reflowable documents (HTML, EPUB). The em parameter controls the
 // base font size in points (default is ~12pt).
 func layoutDocument(doc *fitz.Document, w, h, em float64) {
-	v := reflect.ValueOf(doc).Elem()
-	ctx := unsafe.Pointer(v.Field(0).Pointer())
-	docPtr := unsafe.Pointer(v.Field(2).Pointer())
-	C.fz_layout_document(ctx, docPtr, C.float(w), C.float(h), C.float(em))
+	ctx, docPtr := fitzPointers(doc)
+	// On failure the document keeps its previous layout
+	C.dv_layout_document(ctx, docPtr, C.float(w), C.float(h), C.float(em))
+}
+
+// layoutHTML lays out an HTML document for a virtual page width in points.
+func layoutHTML(doc *fitz.Document, width int) {
+	// Height proportional to width (A4 ratio ~1.414), em=12 (MuPDF default)
+	h := float64(width) * 1.414
+	layoutDocument(doc, float64(width), h, 12)
 }
@@ -0,0 +1,372 @@
+package main
+
+/*
+typedef struct { float x0, y0, x1, y1; } dv_rect;
+
+// Leading fields of fz_link; the list is walked but never modified.
+typedef struct dv_link {
+	int refs;
+	struct dv_link *next;
+	dv_rect rect;
+	char *uri;
+} dv_link;
+
+extern void *fz_load_page(void *ctx, void *doc, int number);
+extern void fz_drop_page(void *ctx, void *page);
+extern dv_link *fz_load_links(void *ctx, void *page);
+extern void fz_drop_link(void *ctx, dv_link *link);
+
+#include "fitzguard.h"
+
+// dv_load_page_links loads the links of a page into *links (NULL if it has
+// none), to be freed with fz_drop_link.
+static int dv_load_page_links(void *ctx, void *doc, int number, dv_link **links) {
+	void *page;
+	*links = NULL;
+	dv_try(ctx) {
+		page = fz_load_page(ctx, doc, number);
+	}
+	dv_catch(ctx) {
+		fz_ignore_error(ctx);
+		return dv_failed;
+	}
+	dv_try(ctx) {
+		*links = fz_load_links(ctx, page);
+	}
+	dv_catch(ctx) {
+		fz_drop_page(ctx, page);
+		fz_ignore_error(ctx);
+		return dv_failed;
+	}
+	fz_drop_page(ctx, page);
+	return 0;
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// hintAlphabet holds the keys used for link hint labels (home row first).
+const hintAlphabet = "asdfghjkl"
+
+// pageLink is a link annotation on a page.
+type pageLink struct {
+	page           int     // 0-indexed PDF page the link is on
+	x0, y0, x1, y1 float64 // link area in page coordinates (points)
+	uri            string  // "#page=3", a named destination, or an external URI
+}
+
+// linkHint pairs a link with the label typed to follow it.
+type linkHint struct {
+	label string
+	link  pageLink
+}
+
+// loadLinks returns the links on a PDF page in reading order.
+func (d *DocumentViewer) loadLinks(pdfPage int) []pageLink {
+	if pdfPage < 0 || pdfPage >= d.doc.NumPage() {
+		return nil
+	}
+	ctx, docPtr := fitzPointers(d.doc)
+	var head *C.dv_link
+	if C.dv_load_page_links(ctx, docPtr, C.int(pdfPage), &head) != 0 || head == nil {
+		return nil
+	}
+	defer C.fz_drop_link(ctx, head)
+
+	var links []pageLink
+	for l := head; l != nil; l = l.next {
+		if l.uri == nil {
+			continue
+		}
+		links = append(links, pageLink{
+			page: pdfPage,
+			x0:   float64(l.rect.x0),
+			y0:   float64(l.rect.y0),
+			x1:   float64(l.rect.x1),
+			y1:   float64(l.rect.y1),
+			uri:  C.GoString(l.uri),
+		})
+	}
+	// MuPDF keeps annotation order; sort top-to-bottom, left-to-right so
+	// labels read naturally
+	sort.SliceStable(links, func(i, j int) bool {
+		if links[i].y0 != links[j].y0 {
+			return links[i].y0 < links[j].y0
+		}
+		return links[i].x0 < links[j].x0
+	})
+	return links
+}
+
+// hintLabels returns n labels of equal length over hintAlphabet, so no label
+// is a prefix of another.
+func hintLabels(n int) []string {
+	length := 1
+	for total := len(hintAlphabet); total < n; total *= len(hintAlphabet) {
+		length++
+	}
+	labels := make([]string, n)
+	for i := range labels {
+		label := make([]byte, length)
+		v := i
+		for j := length - 1; j >= 0; j-- {
+			label[j] = hintAlphabet[v%len(hintAlphabet)]
+			v /= len(hintAlphabet)
+		}
+		labels[i] = string(label)
+	}
+	return labels
+}
+
+// followLink shows a label on every link of the visible page(s) and follows
+// the one whose label is typed. Internal links jump within the document,
+// external ones are handed to the link opener.
+func (d *DocumentViewer) followLink(inputChan <-chan keyEvent) {
+	pages := []int{d.textPages[d.currentPage]}
+	if d.dualPageMode != "" && d.currentPage+1 < len(d.textPages) {
+		pages = append(pages, d.textPages[d.currentPage+1])
+	}
+	var links []pageLink
+	for _, p := range pages {
+		links = append(links, d.loadLinks(p)...)
+	}
+
+	_, rows := d.getTerminalSize()
+	if len(links) == 0 {
+		fmt.Printf("\033[%d;1H\033[K", rows)
+		fmt.Print("No links on this page (press any key)")
+		<-inputChan
+		return
+	}
+
+	labels := hintLabels(len(links))
+	d.linkHints = make([]linkHint, len(links))
+	for i, l := range links {
+		d.linkHints[i] = linkHint{label: labels[i], link: l}
+	}
+	defer func() { d.linkHints = nil }()
+
+	// Labels are painted into the page image; text pages get a list instead
+	if d.dualPageMode != "" || d.continuous || d.getPageContentType(pages[0]) != "text" {
+		d.displayCurrentPage()
+	} else {
+		d.drawLinkList()
+	}
+
+	typed := ""
+	for {
+		fmt.Printf("\033[%d;1H\033[K", rows)
+		fmt.Printf("Follow link: %s\033[2m  (type a label, Esc to cancel)\033[0m", typed)
+
+		ev := <-inputChan
+		switch ev.key {
+		case keyEscape:
+			return
+		case keyBackspace:
+			if len(typed) > 0 {
+				typed = typed[:len(typed)-1]
+			}
+			continue
+		}
+		ch := ev.text()
+		if ch == 'q' {
+			return
+		}
+		if ch == 0 {
+			continue
+		}
+
+		next := typed + strings.ToLower(string(ch))
+		var matches []linkHint
+		for _, h := range d.linkHints {
+			if strings.HasPrefix(h.label, next) {
+				matches = append(matches, h)
+			}
+		}
+		if len(matches) == 0 {
+			continue // not a label: ignore the key
+		}
+		typed = next
+		if len(matches) == 1 && matches[0].label == typed {
+			d.openLink(matches[0].link)
+			return
+		}
+	}
+}
+
+// drawLinkList lists the hints of a page shown as text, where links have
+// no position on screen.
+func (d *DocumentViewer) drawLinkList() {
+	termWidth, termHeight := d.getTerminalSize()
+	d.clearScreen()
+	p := func(s string) { fmt.Print(s + "\r\n") }
+
+	p(strings.Repeat("=", termWidth))
+	p(fmt.Sprintf("Links on page %s", d.pageLabel(d.textPages[d.currentPage])))
+	p(strings.Repeat("=", termWidth))
+
+	for i, h := range d.linkHints {
+		if i >= termHeight-5 {
+			break
+		}
+		line := fmt.Sprintf("  \033[1;30;43m %s \033[0m  %s", h.label, d.linkTarget(h.link))
+		p(line)
+	}
+}
+
+// linkTarget describes where a link goes, for the link list.
+func (d *DocumentViewer) linkTarget(l pageLink) string {
+	if page := d.resolveLinkPage(l.uri); page >= 0 {
+		return "page " + d.pageLabel(page)
+	}
+	return l.uri
+}
+
+// openLink follows a link: internal destinations are recorded in the jump
+// history, external URIs are opened with the link opener.
+func (d *DocumentViewer) openLink(l pageLink) {
+	if page := d.resolveLinkPage(l.uri); page >= 0 {
+		d.recordJump()
+		d.jumpToPage(page + 1)
+		return
+	}
+	if strings.HasPrefix(l.uri, "#") {
+		return // internal destination that does not resolve
+	}
+
+	args := linkOpener()
+	cmd := exec.Command(args[0], append(args[1:], l.uri)...)
+	if err := cmd.Start(); err != nil {
+		_, rows := d.getTerminalSize()
+		fmt.Printf("\033[%d;1H\033[K", rows)
+		fmt.Printf("Cannot open link with %s: %v", args[0], err)
+		return
+	}
+	go cmd.Wait()
+}
+
+// linkOpener returns the command external links are passed to:
+// $DOCVIEWER_OPENER (e.g. "firefox --new-tab") or the platform default.
+func linkOpener() []string {
+	if opener := strings.Fields(os.Getenv("DOCVIEWER_OPENER")); len(opener) > 0 {
+		return opener
+	}
+	if runtime.GOOS == "darwin" {
+		return []string{"open"}
+	}
+	return []string{"xdg-open"}
+}
+
+// decoratePageImage paints the boxes of search occurrences and the active
+// link hint labels of a page onto its rendered image. Painting into the
+// image (rather than printing text over it) keeps labels visible with
+// graphics protocols that draw above text. It also returns the cache key of
+// the image, nil when link hints are painted on it; images with only search
+// occurrences are cached, so redraws that do not change them (scrolling,
+// panning) reuse them.
+func (d *DocumentViewer) decoratePageImage(pdfPage int, img image.Image, dpi float64) (image.Image, *renderKey) {
+	key := d.pageKey(pdfPage, dpi)
+	var hints []linkHint
+	for _, h := range d.linkHints {
+		if h.link.page == pdfPage {
+			hints = append(hints, h)
+		}
+	}
+	if len(hints) == 0 && !d.hasSearchHits(pdfPage) {
+		return img, &key
+	}
+	gen := d.cache.generation()
+	if len(hints) == 0 {
+		key.marks = d.searchMarks()
+		if marked, ok := d.cache.get(key); ok {
+			return marked, &key
+		}
+	}
+
+	bounds := img.Bounds()
+	dst := image.NewRGBA(bounds)
+	draw.Draw(dst, bounds, img, bounds.Min, draw.Src)
+
+	origin, err := d.doc.Bound(pdfPage)
+	if err != nil {
+		return img, nil
+	}
+	d.paintSearchHits(dst, pdfPage, origin, dpi)
+	if len(hints) == 0 {
+		d.cache.putMarked(key, dst, gen)
+		return dst, &key
+	}
+	scale := dpi / 72.0
+	// Labels about one terminal line tall
+	_, cellHeight := d.getTerminalCellSize()
+	glyphScale := int(cellHeight/13.0 + 0.5)
+	if glyphScale < 1 {
+		glyphScale = 1
+	}
+
+	for _, h := range hints {
+		x0 := bounds.Min.X + int((h.link.x0-float64(origin.Min.X))*scale)
+		y0 := bounds.Min.Y + int((h.link.y0-float64(origin.Min.Y))*scale)
+		x1 := bounds.Min.X + int((h.link.x1-float64(origin.Min.X))*scale)
+		y1 := bounds.Min.Y + int((h.link.y1-float64(origin.Min.Y))*scale)
+
+		// Underline the link area, then put the label at its top-left corner
+		underline := image.Rect(x0, y1-glyphScale, x1, y1).Intersect(bounds)
+		draw.Draw(dst, underline, &image.Uniform{color.RGBA{230, 160, 0, 255}}, image.Point{}, draw.Src)
+		drawHintLabel(dst, x0, y0, strings.ToUpper(h.label), glyphScale)
+	}
+	return dst, nil
+}
+
+// drawHintLabel draws text in black on a yellow box with its top-left corner
+// at (x, y), enlarging the 7x13 bitmap font by an integer factor.
+func drawHintLabel(dst *image.RGBA, x, y int, text string, scale int) {
+	face := basicfont.Face7x13
+	label := image.NewRGBA(image.Rect(0, 0, face.Advance*len(text)+2, face.Height+1))
+	draw.Draw(label, label.Bounds(), &image.Uniform{color.RGBA{255, 215, 0, 255}}, image.Point{}, draw.Src)
+	drawer := font.Drawer{
+		Dst:  label,
+		Src:  image.Black,
+		Face: face,
+		Dot:  fixed.P(1, face.Ascent),
+	}
+	drawer.DrawString(text)
+
+	// Keep the label on the image
+	w, h := label.Bounds().Dx()*scale, label.Bounds().Dy()*scale
+	if x+w > dst.Bounds().Max.X {
+		x = dst.Bounds().Max.X - w
+	}
+	if y+h > dst.Bounds().Max.Y {
+		y = dst.Bounds().Max.Y - h
+	}
+	if x < dst.Bounds().Min.X {
+		x = dst.Bounds().Min.X
+	}
+	if y < dst.Bounds().Min.Y {
+		y = dst.Bounds().Min.Y
+	}
+
+	for ly := 0; ly < h; ly++ {
+		for lx := 0; lx < w; lx++ {
+			if image.Pt(x+lx, y+ly).In(dst.Bounds()) {
+				dst.SetRGBA(x+lx, y+ly, label.RGBAAt(lx/scale, ly/scale))
+			}
+		}
+	}
+}
@@ -4,28 +4,44 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 )
 
 func main() {
-	// Handle --help and -h flags
-	if len(os.Args) > 1 {
-		arg := os.Args[1]
-		if arg == "--help" || arg == "-h" {
+	args := os.Args[1:]
+	cellMode := ""
+
+	// Handle options before the path
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		opt := args[0]
+		switch {
+		case opt == "--help" || opt == "-h":
 			printHelp()
 			return
-		}
-		if arg == "--version" || arg == "-v" {
+		case opt == "--version" || opt == "-v":
 			fmt.Println("docviewer 1.0.0")
 			return
+		case opt == "--cells":
+			cellMode = defaultCellMode()
+		case strings.HasPrefix(opt, "--cells="):
+			cellMode = strings.TrimPrefix(opt, "--cells=")
+			if !slices.Contains(cellModes, cellMode) {
+				fmt.Printf("Unknown --cells mode: %s (use %s)\n", cellMode, strings.Join(cellModes, ", "))
+				return
+			}
+		default:
+			fmt.Printf("Unknown option: %s\n", opt)
+			return
 		}
+		args = args[1:]
 	}
 
 	// Determine if user provided an argument
-	hasArg := len(os.Args) > 1
+	hasArg := len(args) > 0
 	arg := "."
 	if hasArg {
-		arg = os.Args[1]
+		arg = args[0]
 	}
 
 	// Expand ~ to home directory
@@ -37,17 +53,23 @@ func main() {
 	// When no argument given, do a broad search across common directories
 	if !hasArg {
 		// Main loop for broad search mode
+		next := "" // document to open instead of showing the picker
 		for {
-			filePath, err := selectFileWithPickerBroadSearch()
-			if err != nil {
-				fmt.Printf("File selection cancelled: %v\n", err)
-				return
+			filePath := next
+			if filePath == "" {
+				var err error
+				filePath, err = selectFileWithPickerBroadSearch()
+				if err != nil {
+					fmt.Printf("File selection cancelled: %v\n", err)
+					return
+				}
 			}
 			if filePath == "" {
 				return
 			}
 
 			viewer := NewDocumentViewer(filePath)
+			viewer.cellMode = cellMode
 			if err := viewer.Open(); err != nil {
 				fmt.Printf("Error opening file: %v\n", err)
 				return
@@ -57,6 +79,7 @@ func main() {
 			if !wantBack {
 				return
 			}
+			next = viewer.openPath
 		}
 	}
 
@@ -76,11 +99,15 @@ func main() {
 
 	// Main loop - allows going back to file picker
 	firstFile := true
+	next := "" // document to open instead of showing the picker
 	for {
 		var filePath string
 		var err error
 
-		if isDir || !firstFile {
+		if next != "" {
+			// Opened from the viewer with :open
+			filePath, next = next, ""
+		} else if isDir || !firstFile {
 			// Search within directory
 			filePath, err = selectFileWithPickerInDir(searchDir)
 			if err != nil {
@@ -109,6 +136,7 @@ func main() {
 		}
 
 		viewer := NewDocumentViewer(filePath)
+		viewer.cellMode = cellMode
 		if err := viewer.Open(); err != nil {
 			fmt.Printf("Error opening file: %v\n", err)
 			return
@@ -118,6 +146,7 @@ func main() {
 		if !wantBack {
 			return
 		}
+		next = viewer.openPath
 		// Loop continues - go back to file picker
 	}
 }
@@ -136,25 +165,41 @@ ARGUMENTS:
 OPTIONS:
     -h, --help       Show this help message
     -v, --version    Show version
+    --cells[=MODE]   Draw pages with text cells instead of terminal graphics
+                     (truecolor, 256 or braille; used automatically when the
+                     terminal has no graphics protocol)
 
 SUPPORTED FORMATS:
     PDF, EPUB, DOCX, HTML
 
 KEYBOARD SHORTCUTS:
     Navigation:
-        j, Space, Down, Right    Next page
-        k, Up, Left              Previous page
-        g                        Go to specific page
+        j, Space, Down, Right    Next page (scrolls first on pages taller than the screen)
+        k, Up, Left              Previous page (scrolls first on pages taller than the screen)
+        H, L                     Pan left/right on pages wider than the screen
+        g                        Go to page (printed label, or #N physical)
+        gg, G                    First page, last page
+        NG, N%                   Page N, N percent through the document
+        N + j/k/J/K/n/N          Repeat a motion N times (e.g. 5j)
+        o                        Table of contents
+        m                        Bookmark current page
+        '                        List bookmarks
+        l                        Follow a link (type its hint label)
+        Ctrl-O, Ctrl-I (Tab)     Back/forward in jump history
         b                        Back to file picker
 
     Search:
-        /                        Search in document
-        n                        Next search result
-        N                        Previous search result
+        /                        Search in document (smart case; re:<regexp> for a
+                                 regular expression; Ctrl-W toggles whole words)
+        n                        Next search match
+        N                        Previous search match
+        s                        List search results with the text around them
+        ESC                      Stop a search still running
 
     Display:
         t                        Toggle view mode (auto/text/image)
         f                        Cycle fit modes (height/width/auto)
+        c                        Toggle continuous scroll (pages stacked vertically)
         i                        Toggle dark mode (smart invert, preserves hue)
         D                        Toggle dark mode (simple invert)
         +, =                     Zoom in
@@ -163,6 +208,8 @@ KEYBOARD SHORTCUTS:
         d                        Show debug info
 
     Other:
+        :                        Command line (:zoom 135, :fit width, :set dark smart,
+                                 :page 120, :dual horizontal, :open <path>, :export)
         h                        Show help
         q                        Quit
 
@@ -172,6 +219,10 @@ EXAMPLES:
     docviewer paper.pdf          Open file directly
 
 For LaTeX workflows, the viewer auto-reloads when the file changes.
+The last page and view settings of each document are restored when it is
+reopened (saved in $XDG_STATE_HOME/docviewer/state.json).
+External links are opened with $DOCVIEWER_OPENER if set (e.g. "firefox"),
+otherwise with xdg-open (open on macOS).
 `
 	fmt.Print(help)
 }
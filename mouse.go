@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"image"
+)
+
+// While the viewer runs, the terminal reports mouse events (SGR encoding,
+// with motion while a button is held). The wheel turns pages like j/k, or
+// scrolls zoomed and continuous views; with Shift it pans sideways. A click
+// is mapped back to page coordinates through where the last redraw put each
+// page on screen: clicking a link follows it, and clicking the second page
+// in dual-page mode brings it to the front. Dragging pans the view.
+
+const (
+	mouseOn  = "\033[?1000h\033[?1002h\033[?1006h" // buttons, drag motion, SGR encoding
+	mouseOff = "\033[?1006l\033[?1002l\033[?1000l"
+)
+
+// Mouse buttons as reported in mouseEvent.button.
+const (
+	mouseLeft      = 0
+	mouseWheelUp   = 64
+	mouseWheelDown = 65
+)
+
+// screenImage is where the last redraw printed the page image, for mapping
+// mouse positions back to pages.
+type screenImage struct {
+	row, col int        // cell of the top-left corner, 1-based
+	pages    []pageArea // the pages it shows
+}
+
+// pageArea is the part of the printed image showing one page.
+type pageArea struct {
+	page   int             // PDF page
+	rect   image.Rectangle // pixels of the printed image
+	origin image.Point     // page image pixel shown at rect.Min
+	dpi    float64         // resolution the page image is rendered at
+}
+
+// mouseDrag is a left button press being tracked until its release.
+type mouseDrag struct {
+	x, y  int  // cell of the last position
+	moved bool // dragged rather than clicked
+}
+
+// imageAt moves the cursor to the row the page image starts on and
+// remembers it for mapping mouse clicks.
+func (d *DocumentViewer) imageAt(row int) {
+	fmt.Printf("\033[%d;1H", row)
+	d.screen.row = row
+}
+
+// addPageArea records that rect of the image being composed shows a page
+// rendered for a termWidth x termHeight area, from origin in its image on.
+func (d *DocumentViewer) addPageArea(pdfPage int, rect image.Rectangle, origin image.Point, termWidth, termHeight int) {
+	dpi, err := d.pageDPI(pdfPage, termWidth, termHeight)
+	if err != nil {
+		return
+	}
+	d.screen.pages = append(d.screen.pages, pageArea{page: pdfPage, rect: rect, origin: origin, dpi: dpi})
+}
+
+// handleMouse acts on a mouse event and reports whether the page has to be
+// redrawn.
+func (d *DocumentViewer) handleMouse(ev keyEvent) bool {
+	m := ev.mouse
+	switch {
+	case m.button == mouseWheelUp || m.button == mouseWheelDown:
+		down := m.button == mouseWheelDown
+		if ev.mod&modShift != 0 {
+			if d.viewMaxX == 0 {
+				return false
+			}
+			dir := -1
+			if down {
+				dir = 1
+			}
+			d.panHorizontal(dir)
+			return true
+		}
+		if down {
+			d.handleInput('j')
+		} else {
+			d.handleInput('k')
+		}
+		return true
+	case m.button != mouseLeft:
+		return false
+	case m.release:
+		drag := d.drag
+		d.drag = nil
+		if drag == nil || drag.moved {
+			return false
+		}
+		return d.clickAt(m.x, m.y)
+	case m.motion:
+		if d.drag == nil {
+			return false
+		}
+		dx, dy := m.x-d.drag.x, m.y-d.drag.y
+		d.drag.x, d.drag.y = m.x, m.y
+		if dx == 0 && dy == 0 {
+			return false
+		}
+		d.drag.moved = true
+		return d.dragView(dx, dy)
+	}
+	d.drag = &mouseDrag{x: m.x, y: m.y}
+	return false
+}
+
+// dragView pans the view with the pointer, which moved dx x dy cells.
+func (d *DocumentViewer) dragView(dx, dy int) bool {
+	pixelsPerChar, pixelsPerLine := d.getTerminalCellSize()
+	x, y := d.viewX, d.viewY
+	d.viewX = min(max(d.viewX-int(float64(dx)*pixelsPerChar), 0), d.viewMaxX)
+	if d.continuous {
+		if dy != 0 {
+			d.scrollContinuous(-dy)
+			return true
+		}
+	} else {
+		d.viewY = min(max(d.viewY-int(float64(dy)*pixelsPerLine), 0), d.viewMaxY)
+	}
+	return d.viewX != x || d.viewY != y
+}
+
+// clickAt follows the link under the cell clicked, or brings the page
+// clicked to the front in dual-page mode.
+func (d *DocumentViewer) clickAt(x, y int) bool {
+	area, px, ok := d.pageAt(x, y)
+	if !ok {
+		return false
+	}
+	if origin, err := d.doc.Bound(area.page); err == nil {
+		scale := area.dpi / 72.0
+		ptX := float64(px.X)/scale + float64(origin.Min.X)
+		ptY := float64(px.Y)/scale + float64(origin.Min.Y)
+		for _, l := range d.loadLinks(area.page) {
+			if ptX >= l.x0 && ptX <= l.x1 && ptY >= l.y0 && ptY <= l.y1 {
+				d.openLink(l)
+				return true
+			}
+		}
+	}
+	if d.dualPageMode != "" && area.page != d.textPages[d.currentPage] {
+		for i, p := range d.textPages {
+			if p == area.page {
+				d.currentPage = i
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// pageAt returns the page shown at a cell and the pixel of its page image
+// at the middle of the cell.
+func (d *DocumentViewer) pageAt(x, y int) (pageArea, image.Point, bool) {
+	if d.screen.row == 0 || d.screen.col == 0 {
+		return pageArea{}, image.Point{}, false
+	}
+	pixelsPerChar, pixelsPerLine := d.getTerminalCellSize()
+	p := image.Pt(
+		int((float64(x-d.screen.col)+0.5)*pixelsPerChar),
+		int((float64(y-d.screen.row)+0.5)*pixelsPerLine),
+	)
+	for _, area := range d.screen.pages {
+		if p.In(area.rect) {
+			return area, p.Sub(area.rect.Min).Add(area.origin), true
+		}
+	}
+	return pageArea{}, image.Point{}, false
+}
@@ -0,0 +1,305 @@
+package main
+
+/*
+#include <stdlib.h>
+
+// Location of a page inside a (possibly multi-chapter) document, as in fz_location.
+typedef struct { int chapter; int page; } dv_location;
+
+extern dv_location fz_resolve_link(void *ctx, void *doc, const char *uri, float *xp, float *yp);
+extern int fz_page_number_from_location(void *ctx, void *doc, dv_location loc);
+extern int fz_is_external_link(void *ctx, const char *uri);
+
+#include "fitzguard.h"
+
+// dv_resolve_link_page stores the 0-indexed page an internal link points to
+// in *page, -1 if it cannot be resolved.
+static int dv_resolve_link_page(void *ctx, void *doc, const char *uri, int *page) {
+	*page = -1;
+	dv_try(ctx) {
+		dv_location loc = fz_resolve_link(ctx, doc, uri, NULL, NULL);
+		if (loc.chapter >= 0 && loc.page >= 0)
+			*page = fz_page_number_from_location(ctx, doc, loc);
+	}
+	dv_catch(ctx) {
+		fz_ignore_error(ctx);
+		return dv_failed;
+	}
+	return 0;
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"strings"
+	"unsafe"
+)
+
+// outlineEntry is one node of the document outline (table of contents).
+type outlineEntry struct {
+	level int    // nesting depth, starting at 1
+	title string // entry title as stored in the document
+	page  int    // 0-indexed PDF page, -1 if the entry has no internal target
+}
+
+// loadOutline reads the document outline and resolves every entry to an
+// absolute page number. Returns nil if the document has no outline.
+func (d *DocumentViewer) loadOutline() []outlineEntry {
+	toc, err := d.doc.ToC()
+	if err != nil {
+		return nil
+	}
+
+	entries := make([]outlineEntry, 0, len(toc))
+	for _, item := range toc {
+		page := item.Page
+		// Outline pages are chapter-relative (EPUB), so prefer resolving the URI
+		if item.URI != "" {
+			page = d.resolveLinkPage(item.URI)
+		}
+		if page >= d.doc.NumPage() {
+			page = -1
+		}
+		entries = append(entries, outlineEntry{
+			level: item.Level,
+			title: strings.TrimSpace(item.Title),
+			page:  page,
+		})
+	}
+	return entries
+}
+
+// resolveLinkPage maps an internal link URI (e.g. "#page=5" or a named
+// destination) to a 0-indexed page number. Returns -1 for external links
+// and destinations that cannot be resolved.
+func (d *DocumentViewer) resolveLinkPage(uri string) int {
+	ctx, docPtr := fitzPointers(d.doc)
+	curi := C.CString(uri)
+	defer C.free(unsafe.Pointer(curi))
+
+	if C.fz_is_external_link(ctx, curi) != 0 {
+		return -1
+	}
+	var page C.int
+	if C.dv_resolve_link_page(ctx, docPtr, curi, &page) != 0 {
+		return -1
+	}
+	return int(page)
+}
+
+// currentOutlineEntry returns the index of the entry covering the current
+// page: the last entry (in document order) that starts at or before it.
+func (d *DocumentViewer) currentOutlineEntry(entries []outlineEntry) int {
+	current := d.textPages[d.currentPage]
+	best := -1
+	bestPage := -1
+	for i, e := range entries {
+		if e.page >= 0 && e.page <= current && e.page >= bestPage {
+			best = i
+			bestPage = e.page
+		}
+	}
+	return best
+}
+
+func outlineHasChildren(entries []outlineEntry, i int) bool {
+	return i+1 < len(entries) && entries[i+1].level > entries[i].level
+}
+
+// visibleOutlineEntries returns the indices of entries not hidden inside a
+// collapsed parent.
+func visibleOutlineEntries(entries []outlineEntry, expanded map[int]bool) []int {
+	var visible []int
+	hideBelow := 0 // level of the collapsed parent currently hiding entries
+	for i, e := range entries {
+		if hideBelow > 0 {
+			if e.level > hideBelow {
+				continue
+			}
+			hideBelow = 0
+		}
+		visible = append(visible, i)
+		if outlineHasChildren(entries, i) && !expanded[i] {
+			hideBelow = e.level
+		}
+	}
+	return visible
+}
+
+// showOutline displays the outline as a collapsible tree and jumps to the
+// chosen entry. The entry containing the current page is highlighted.
+func (d *DocumentViewer) showOutline(inputChan <-chan keyEvent) {
+	entries := d.loadOutline()
+	if len(entries) == 0 {
+		_, rows := d.getTerminalSize()
+		fmt.Printf("\033[%d;1H\033[K", rows)
+		fmt.Print("No table of contents in this document (press any key)")
+		<-inputChan
+		return
+	}
+
+	// Expand the path down to the current entry
+	current := d.currentOutlineEntry(entries)
+	expanded := make(map[int]bool)
+	if current >= 0 {
+		level := entries[current].level
+		for i := current - 1; i >= 0 && level > 1; i-- {
+			if entries[i].level < level {
+				expanded[i] = true
+				level = entries[i].level
+			}
+		}
+	}
+
+	visible := visibleOutlineEntries(entries, expanded)
+	selected := 0
+	for i, idx := range visible {
+		if idx == current {
+			selected = i
+		}
+	}
+	offset := 0
+
+	for {
+		termWidth, termHeight := d.getTerminalSize()
+		// header (3) + footer (2)
+		listHeight := termHeight - 5
+		if listHeight < 1 {
+			listHeight = 1
+		}
+		if selected < offset {
+			offset = selected
+		} else if selected >= offset+listHeight {
+			offset = selected - listHeight + 1
+		}
+
+		d.drawOutline(entries, visible, expanded, selected, offset, current, termWidth, listHeight)
+
+		ch := (<-inputChan).char()
+		idx := visible[selected]
+		switch ch {
+		case 'q', 'o', 27: // close
+			return
+		case 'j':
+			if selected < len(visible)-1 {
+				selected++
+			}
+		case 'k':
+			if selected > 0 {
+				selected--
+			}
+		case 'J':
+			selected += listHeight
+			if selected > len(visible)-1 {
+				selected = len(visible) - 1
+			}
+		case 'K':
+			selected -= listHeight
+			if selected < 0 {
+				selected = 0
+			}
+		case 'g':
+			selected = 0
+		case 'G':
+			selected = len(visible) - 1
+		case 'l':
+			if outlineHasChildren(entries, idx) {
+				expanded[idx] = true
+			}
+		case 'h':
+			if outlineHasChildren(entries, idx) && expanded[idx] {
+				expanded[idx] = false
+			} else {
+				// Move to the parent entry
+				for i := selected - 1; i >= 0; i-- {
+					if entries[visible[i]].level < entries[idx].level {
+						selected = i
+						break
+					}
+				}
+			}
+		case ' ', 9: // Space/Tab: toggle
+			if outlineHasChildren(entries, idx) {
+				expanded[idx] = !expanded[idx]
+			}
+		case 13, 10: // Enter: jump
+			if entries[idx].page >= 0 {
+				d.recordJump()
+				d.jumpToPage(entries[idx].page + 1)
+				return
+			}
+		}
+
+		// Keep the selection on the same entry when the tree changes shape
+		target := visible[selected]
+		visible = visibleOutlineEntries(entries, expanded)
+		for i, v := range visible {
+			if v == target {
+				selected = i
+				break
+			}
+		}
+	}
+}
+
+func (d *DocumentViewer) drawOutline(entries []outlineEntry, visible []int, expanded map[int]bool, selected, offset, current, termWidth, listHeight int) {
+	d.clearScreen()
+	p := func(s string) { fmt.Print(s + "\r\n") }
+
+	p(strings.Repeat("=", termWidth))
+	p(fmt.Sprintf("Table of Contents (%d entries)", len(entries)))
+	p(strings.Repeat("=", termWidth))
+
+	end := offset + listHeight
+	if end > len(visible) {
+		end = len(visible)
+	}
+	for i := offset; i < end; i++ {
+		idx := visible[i]
+		e := entries[idx]
+
+		marker := "  "
+		if outlineHasChildren(entries, idx) {
+			if expanded[idx] {
+				marker = "▾ "
+			} else {
+				marker = "▸ "
+			}
+		}
+		pageStr := ""
+		if e.page >= 0 {
+			pageStr = d.pageLabel(e.page)
+		}
+
+		indent := strings.Repeat("  ", e.level-1)
+		title := indent + marker + e.title
+		// Leave room for the page number column
+		maxTitle := termWidth - len(pageStr) - 4
+		if maxTitle < 1 {
+			maxTitle = 1
+		}
+		titleRunes := []rune(title)
+		if len(titleRunes) > maxTitle {
+			title = string(titleRunes[:maxTitle-1]) + "…"
+			titleRunes = []rune(title)
+		}
+		pad := termWidth - len(titleRunes) - len(pageStr) - 2
+		if pad < 1 {
+			pad = 1
+		}
+		line := " " + title + strings.Repeat(" ", pad) + pageStr
+
+		switch {
+		case i == selected:
+			fmt.Print("\033[7m" + line + "\033[0m\r\n") // reverse video
+		case idx == current:
+			fmt.Print("\033[1;33m" + line + "\033[0m\r\n") // current section
+		default:
+			p(line)
+		}
+	}
+
+	fmt.Print("\r\n")
+	fmt.Print("\033[2m  j/k: Move  Enter: Jump  l/h/Space: Expand/Collapse  q/Esc: Close\033[0m")
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"image"
+)
+
+// Panning: pages rendered larger than the screen (fit to width, zoom above
+// 100%) are cropped to a viewport that j/k scroll and H/L move sideways.
+// viewX/viewY are the viewport offsets in image pixels; viewMaxX/viewMaxY
+// are the largest offsets for the page on screen (0 when it fits).
+
+// cropToViewport returns the part of a page image inside the viewport of
+// viewW x viewH pixels and records how far the page can be panned.
+func (d *DocumentViewer) cropToViewport(img image.Image, viewW, viewH int) image.Image {
+	b := img.Bounds()
+	d.viewMaxX = max(0, b.Dx()-viewW)
+	d.viewMaxY = max(0, b.Dy()-viewH)
+	d.viewX = min(max(d.viewX, 0), d.viewMaxX)
+	d.viewY = min(max(d.viewY, 0), d.viewMaxY)
+	if d.viewMaxX == 0 && d.viewMaxY == 0 {
+		return img
+	}
+
+	sub, ok := img.(interface {
+		SubImage(r image.Rectangle) image.Image
+	})
+	if !ok {
+		return img
+	}
+	view := image.Rect(d.viewX, d.viewY, d.viewX+viewW, d.viewY+viewH).Add(b.Min)
+	return sub.SubImage(view.Intersect(b))
+}
+
+// scrollStep is the distance j/k and H/L move the viewport: half a screen.
+func (d *DocumentViewer) scrollStep() (int, int) {
+	cols, rows := d.getTerminalSize()
+	pixelsPerChar, pixelsPerLine := d.getTerminalCellSize()
+	return int(float64(cols/2) * pixelsPerChar), int(float64(rows/2) * pixelsPerLine)
+}
+
+// scrollDown scrolls towards the bottom of the page, moving to the top of
+// the next page once the bottom edge is in view.
+func (d *DocumentViewer) scrollDown() {
+	if d.continuous {
+		d.scrollContinuous(continuousScrollLines)
+		return
+	}
+	if d.viewY < d.viewMaxY {
+		_, step := d.scrollStep()
+		d.viewY = min(d.viewY+step, d.viewMaxY)
+		return
+	}
+	if d.currentPage < len(d.textPages)-1 {
+		d.currentPage++
+		d.viewY = 0
+	}
+}
+
+// scrollUp scrolls towards the top of the page, moving to the bottom of the
+// previous page once the top edge is in view.
+func (d *DocumentViewer) scrollUp() {
+	if d.continuous {
+		d.scrollContinuous(-continuousScrollLines)
+		return
+	}
+	if d.viewY > 0 {
+		_, step := d.scrollStep()
+		d.viewY = max(d.viewY-step, 0)
+		return
+	}
+	if d.currentPage > 0 {
+		d.currentPage--
+		// Pages of a document are usually the same size; rendering clamps
+		// this to the real bottom edge
+		d.viewY = d.viewMaxY
+	}
+}
+
+// panHorizontal moves the viewport left (dir < 0) or right (dir > 0).
+func (d *DocumentViewer) panHorizontal(dir int) {
+	step, _ := d.scrollStep()
+	d.viewX = min(max(d.viewX+dir*step, 0), d.viewMaxX)
+}
+
+// panIndicator returns the status bar tag with the viewport position when
+// the page does not fit on screen.
+func (d *DocumentViewer) panIndicator() string {
+	s := ""
+	if d.viewMaxY > 0 {
+		s += fmt.Sprintf(" [scroll:%d%%]", d.viewY*100/d.viewMaxY)
+	}
+	if d.viewMaxX > 0 {
+		s += fmt.Sprintf(" [pan:%d%%]", d.viewX*100/d.viewMaxX)
+	}
+	return s
+}
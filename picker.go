@@ -3,7 +3,10 @@ package main
 import (
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"golang.org/x/term"
 )
@@ -40,71 +43,84 @@ func (fp *FilePicker) Run() (string, error) {
 	defer term.Restore(int(os.Stdin.Fd()), oldState)
 	fmt.Print("\033[?25l")
 	defer fmt.Print("\033[?25h") // Show cursor on exit
+
+	// Redraw for the new size when the terminal is resized
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+	defer signal.Stop(winch)
+
 	fp.updateResults()
+	keys := newKeyReader()
 	for {
 		fp.render()
-		char := fp.readChar()
-		switch char {
-		case 3: // Ctrl+C
+		ev, resized := fp.waitKey(keys, winch)
+		if resized {
+			fp.updateSize()
+			continue
+		}
+		switch ev.key {
+		case keyEscape:
 			return "", fmt.Errorf("cancelled")
-		case 27: // ESC or arrow keys
-			if fp.handleEscapeSequence() {
-				return "", fmt.Errorf("cancelled")
+		case keyUp:
+			if fp.selectedIndex > 0 {
+				fp.selectedIndex--
+				fp.ensureSelectedVisible()
 			}
-		case 127, 8: // Backspace/Delete
-			if len(fp.query) > 0 {
-				fp.query = fp.query[:len(fp.query)-1]
+		case keyDown:
+			if fp.selectedIndex < len(fp.results)-1 {
+				fp.selectedIndex++
+				fp.ensureSelectedVisible()
+			}
+		case keyBackspace:
+			if query := []rune(fp.query); len(query) > 0 {
+				fp.query = string(query[:len(query)-1])
 				fp.updateResults()
 			}
-		case 13: // Enter
+		case keyEnter:
 			if len(fp.results) > 0 && fp.selectedIndex < len(fp.results) {
 				return fp.results[fp.selectedIndex].Path, nil
 			}
-		case 9: // Tab
+		case keyTab:
 			if len(fp.results) > 0 {
 				fp.selectedIndex = (fp.selectedIndex + 1) % len(fp.results)
 				fp.ensureSelectedVisible()
 			}
-		default:
-			if char >= 32 && char < 127 {
-				fp.query += string(char)
+		case keyRune:
+			if ev.r == 'c' && ev.mod&modCtrl != 0 {
+				return "", fmt.Errorf("cancelled")
+			}
+			if r := ev.text(); r != 0 {
+				fp.query += string(r)
 				fp.updateResults()
 			}
 		}
 	}
 }
 
-func (fp *FilePicker) handleEscapeSequence() bool {
-	seq := make([]byte, 2)
-	n, _ := os.Stdin.Read(seq)
-	if n == 0 {
-		return true
-	}
-	if n >= 2 && seq[0] == '[' {
-		switch seq[1] {
-		case 'A': // Up arrow
-			if fp.selectedIndex > 0 {
-				fp.selectedIndex--
-				fp.ensureSelectedVisible()
-			}
-		case 'B': // Down arrow
-			if fp.selectedIndex < len(fp.results)-1 {
-				fp.selectedIndex++
-				fp.ensureSelectedVisible()
-			}
+// waitKey reads the next key, or returns resized once the terminal has
+// been resized and kept its size for resizeDebounce.
+func (fp *FilePicker) waitKey(keys *keyReader, winch <-chan os.Signal) (ev keyEvent, resized bool) {
+	var resizeTimeout <-chan time.Time
+	for {
+		select {
+		case <-winch:
+			resizeTimeout = time.After(resizeDebounce)
+		case <-resizeTimeout:
+			return keyEvent{}, true
+		default:
+		}
+		if ev, ok := keys.next(50 * time.Millisecond); ok {
+			return ev, false
 		}
 	}
-
-	return false
 }
 
-func (fp *FilePicker) readChar() byte {
-	buf := make([]byte, 1)
-	n, _ := os.Stdin.Read(buf)
-	if n > 0 {
-		return buf[0]
+// updateSize re-reads the terminal size and keeps the selection in view.
+func (fp *FilePicker) updateSize() {
+	if width, height, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
+		fp.termWidth, fp.termHeight = width, height
 	}
-	return 0
+	fp.ensureSelectedVisible()
 }
 
 func (fp *FilePicker) updateResults() {
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// At startup the terminal is asked what it can do, all queries in one
+// write: the Kitty graphics query, XTVERSION (its name and version), the
+// text area and cell size in pixels (CSI 14 t, CSI 16 t), the Kitty
+// keyboard protocol query and, last, DA1, which every terminal answers and
+// which lists Sixel support. Terminals answer in order, so the DA1 reply
+// ends the probe; terminals that answer nothing cost probeTimeout.
+
+// probeTimeout is how long to wait for the terminal's answers.
+const probeTimeout = 250 * time.Millisecond
+
+const probeQueries = "\033_Gi=31,s=1,v=1,a=q,t=d,f=24;AAAA\033\\" + // Kitty graphics
+	"\033[>0q" + // XTVERSION
+	"\033[14t" + // text area size in pixels
+	"\033[16t" + // cell size in pixels
+	"\033[?u" + // Kitty keyboard protocol
+	"\033[c" // DA1
+
+var (
+	da1Reply       = regexp.MustCompile(`\x1b\[\?([0-9;]*)c`)
+	kittyReply     = regexp.MustCompile(`\x1b_Gi=31;([^\x1b]*)\x1b\\`)
+	xtversionReply = regexp.MustCompile(`\x1bP>\|([^\x1b]*)\x1b\\`)
+	windowReply    = regexp.MustCompile(`\x1b\[([46]);([0-9]+);([0-9]+)t`)
+	keyboardReply  = regexp.MustCompile(`\x1b\[\?[0-9]*u`)
+)
+
+// termCaps is what the terminal reported about itself.
+type termCaps struct {
+	probed     bool    // the terminal answered DA1
+	kitty      bool    // Kitty graphics protocol
+	kittyKeys  bool    // Kitty keyboard protocol
+	sixel      bool    // Sixel graphics (DA1 attribute 4)
+	name       string  // XTVERSION reply, e.g. "kitty(0.35.2)" or "WezTerm 20240203-110809-5046fc22"
+	cellWidth  float64 // cell size in pixels; 0 if unknown
+	cellHeight float64
+}
+
+// probeTerminal queries the terminal on /dev/tty. It must run before the
+// input goroutine starts reading, since it reads the answers itself.
+func probeTerminal() termCaps {
+	var caps termCaps
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return caps
+	}
+	// A file of its own, so that the reads can time out
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return caps
+	}
+	defer tty.Close()
+
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return caps
+	}
+	defer term.Restore(fd, oldState)
+
+	if _, err := tty.WriteString(probeQueries); err != nil {
+		return caps
+	}
+	if tty.SetReadDeadline(time.Now().Add(probeTimeout)) != nil {
+		return caps
+	}
+	var replies []byte
+	buf := make([]byte, 256)
+	for !da1Reply.Match(replies) {
+		n, err := tty.Read(buf)
+		replies = append(replies, buf[:n]...)
+		if err != nil {
+			break
+		}
+	}
+
+	cols, rows := 0, 0
+	if width, height, err := term.GetSize(fd); err == nil {
+		cols, rows = width, height
+	}
+	caps.parse(replies, cols, rows)
+	return caps
+}
+
+// parse fills in caps from the terminal's replies; cols and rows are the
+// terminal size in cells at the time of the query.
+func (caps *termCaps) parse(replies []byte, cols, rows int) {
+	if m := da1Reply.FindSubmatch(replies); m != nil {
+		caps.probed = true
+		caps.sixel = slices.Contains(strings.Split(string(m[1]), ";"), "4")
+	}
+	if m := kittyReply.FindSubmatch(replies); m != nil {
+		caps.kitty = string(m[1]) == "OK"
+	}
+	caps.kittyKeys = keyboardReply.Match(replies)
+	if m := xtversionReply.FindSubmatch(replies); m != nil {
+		caps.name = string(m[1])
+	}
+
+	var areaWidth, areaHeight int
+	for _, m := range windowReply.FindAllSubmatch(replies, -1) {
+		height, _ := strconv.Atoi(string(m[2]))
+		width, _ := strconv.Atoi(string(m[3]))
+		if width <= 0 || height <= 0 {
+			continue
+		}
+		if string(m[1]) == "6" {
+			caps.cellWidth, caps.cellHeight = float64(width), float64(height)
+		} else {
+			areaWidth, areaHeight = width, height
+		}
+	}
+	// Terminals answering only CSI 14 t
+	if caps.cellWidth == 0 && areaWidth > 0 && cols > 0 && rows > 0 {
+		caps.cellWidth = float64(areaWidth) / float64(cols)
+		caps.cellHeight = float64(areaHeight) / float64(rows)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// promptLine reads a line of input on the bottom row of the screen.
+// Returns the entered text and false if the prompt was cancelled with ESC.
+func (d *DocumentViewer) promptLine(inputChan <-chan keyEvent, prompt, initial string) (string, bool) {
+	return d.readLine(inputChan, prompt, initial, nil, nil)
+}
+
+// readLine is promptLine with a history, which Up/Down go through (only
+// entries starting with what was typed, like vim), and completion: Tab
+// completes the line to the longest prefix shared by complete's candidates
+// and then cycles through them, listing them on the row above.
+func (d *DocumentViewer) readLine(inputChan <-chan keyEvent, prompt, initial string, history []string, complete func(string) []string) (string, bool) {
+	cols, rows := d.getTerminalSize()
+	input := []rune(initial)
+	redraw := func() {
+		fmt.Printf("\033[%d;1H\033[K", rows)
+		fmt.Printf("%s%s", prompt, string(input))
+	}
+
+	// History position; len(history) is the line being typed, kept in draft
+	histIdx := len(history)
+	draft := ""
+	// Completion candidates being cycled through; choice -1 before the first
+	var candidates []string
+	choice := -1
+	showCandidates := func() {
+		fmt.Printf("\033[%d;1H\033[K", rows-1)
+		width := 0
+		for i, c := range candidates {
+			if width+len(c)+2 > cols {
+				break
+			}
+			if i == choice {
+				fmt.Printf("\033[7m%s\033[0m  ", c)
+			} else {
+				fmt.Printf("%s  ", c)
+			}
+			width += len(c) + 2
+		}
+		redraw()
+	}
+
+	fmt.Print("\033[?25h") // show cursor
+	defer fmt.Print("\033[?25l")
+	redraw()
+	for {
+		ev := <-inputChan
+		if ev.key != keyTab && candidates != nil {
+			candidates, choice = nil, -1
+			fmt.Printf("\033[%d;1H\033[K", rows-1)
+			redraw()
+		}
+		switch ev.key {
+		case keyEnter:
+			return string(input), true
+		case keyEscape: // cancel
+			return "", false
+		case keyBackspace:
+			if len(input) > 0 {
+				input = input[:len(input)-1]
+				redraw()
+			}
+		case keyUp, keyDown:
+			if histIdx == len(history) {
+				draft = string(input)
+			}
+			step := 1
+			if ev.key == keyUp {
+				step = -1
+			}
+			for i := histIdx + step; i >= 0 && i <= len(history); i += step {
+				if i == len(history) {
+					histIdx, input = i, []rune(draft)
+					break
+				}
+				if strings.HasPrefix(history[i], draft) {
+					histIdx, input = i, []rune(history[i])
+					break
+				}
+			}
+			redraw()
+		case keyTab:
+			if complete == nil {
+				continue
+			}
+			if candidates == nil {
+				candidates = complete(string(input))
+				if len(candidates) == 0 {
+					candidates = nil
+					continue
+				}
+				if prefix := commonPrefix(candidates); len(candidates) == 1 || len(prefix) > len(string(input)) {
+					input = []rune(prefix)
+					if len(candidates) == 1 {
+						candidates = nil
+						redraw()
+						continue
+					}
+					showCandidates()
+					continue
+				}
+			}
+			n := len(candidates)
+			if ev.mod&modShift != 0 {
+				choice = (max(choice, 0) - 1 + n) % n
+			} else {
+				choice = (choice + 1) % n
+			}
+			input = []rune(candidates[choice])
+			showCandidates()
+		default:
+			if ev.char() == 21 { // Ctrl-U: clear the line
+				input = input[:0]
+				redraw()
+			} else if r := ev.text(); r != 0 {
+				input = append(input, r)
+				fmt.Printf("%c", r)
+			}
+		}
+	}
+}
+
+// commonPrefix returns the longest prefix shared by all of ss.
+func commonPrefix(ss []string) string {
+	prefix := ss[0]
+	for _, s := range ss[1:] {
+		for !strings.HasPrefix(s, prefix) {
+			_, size := utf8.DecodeLastRuneInString(prefix)
+			prefix = prefix[:len(prefix)-size]
+		}
+	}
+	return prefix
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gen2brain/go-fitz"
+)
+
+// Pages are rendered by a worker goroutine so that the main loop never waits
+// on MuPDF: a redraw uses cached images, shows a placeholder and the status
+// bar for missing ones, and queues them. When the worker is done the page is
+// redrawn. Newer requests replace queued ones, so only the page the user
+// ended up on is drawn; a render already in progress still completes into
+// the cache, since MuPDF cannot be interrupted through go-fitz.
+
+// openRetryDelay is how long the render worker waits before the pages it
+// could not open the document for are asked for again.
+const openRetryDelay = 250 * time.Millisecond
+
+// openRetries is how many times in a row the render worker fails to open the
+// document before it stops and leaves rendering to the main loop.
+const openRetries = 8
+
+// renderJob asks the render worker for one page image or content check.
+type renderJob struct {
+	key         renderKey
+	detect      bool // check whether key.page has visual content instead of rendering it
+	urgent      bool // wanted on screen now (not a prefetch); signal renderDone
+	gen         int  // cache generation the job was created for
+	layoutWidth int  // HTML page width to lay the document out with; 0 for none
+}
+
+// queueRender records a page missing from the current redraw. The jobs are
+// sent to the worker when the redraw finishes.
+func (d *DocumentViewer) queueRender(job renderJob) {
+	job.urgent = true
+	d.pendingRenders = append(d.pendingRenders, job)
+}
+
+// renderPending reports whether the current redraw is waiting on the worker.
+func (d *DocumentViewer) renderPending() bool {
+	return len(d.pendingRenders) > 0
+}
+
+// renderFailedText is shown in place of a dual-page or continuous composite
+// that could not be drawn.
+func (d *DocumentViewer) renderFailedText() string {
+	if d.renderPending() {
+		return "  [Rendering...]"
+	}
+	return "  [Render failed]"
+}
+
+// drawStatusOnly updates the status bar for the current page without
+// redrawing the page, while more keys are waiting to be handled.
+func (d *DocumentViewer) drawStatusOnly() {
+	termWidth, termHeight := d.getTerminalSize()
+	fmt.Printf("\033[%d;1H\033[2K", termHeight)
+	if d.dualPageMode != "" && !d.continuous {
+		d.displayDualPageInfo(d.currentPage+1 < len(d.textPages), termWidth, "2pg")
+	} else {
+		d.displayPageInfo(d.textPages[d.currentPage], termWidth, "...")
+	}
+	os.Stdout.Sync()
+}
+
+// requestRenders hands the pages missing from the last redraw to the worker,
+// replacing any request it has not started yet.
+func (d *DocumentViewer) requestRenders() {
+	jobs := d.pendingRenders
+	d.pendingRenders = nil
+	if len(jobs) == 0 || d.renderJobs == nil {
+		return
+	}
+	d.sendJobs(d.renderJobs, jobs)
+}
+
+// sendJobs replaces the batch waiting in ch with jobs.
+func (d *DocumentViewer) sendJobs(ch chan []renderJob, jobs []renderJob) {
+	gen := d.cache.generation()
+	layoutWidth := 0
+	if d.isReflowable {
+		layoutWidth = d.htmlPageWidth
+	}
+	for i := range jobs {
+		jobs[i].gen = gen
+		jobs[i].layoutWidth = layoutWidth
+	}
+
+	select {
+	case <-ch:
+	default:
+	}
+	ch <- jobs
+}
+
+// prefetchNeighbours queues the pages around the current one for background
+// rendering, at the size the current page was just rendered with.
+func (d *DocumentViewer) prefetchNeighbours() {
+	if d.prefetchJobs == nil || d.renderSize == [2]int{} {
+		return
+	}
+	var jobs []renderJob
+	// Next, previous, then the one after next (dual-page and continuous
+	// modes show two pages at a time)
+	for _, idx := range []int{d.currentPage + 1, d.currentPage - 1, d.currentPage + 2} {
+		if idx < 0 || idx >= len(d.textPages) {
+			continue
+		}
+		page := d.textPages[idx]
+		dpi, err := d.pageDPI(page, d.renderSize[0], d.renderSize[1])
+		if err != nil {
+			continue
+		}
+		key := d.pageKey(page, dpi)
+		if !d.cache.has(key) {
+			jobs = append(jobs, renderJob{key: key})
+		}
+	}
+	if len(jobs) > 0 {
+		d.sendJobs(d.prefetchJobs, jobs)
+	}
+}
+
+// renderWorker renders requested and prefetched pages into the cache,
+// requested ones first. A fitz.Document must not be used from two
+// goroutines, so the worker opens its own handle on the file and reopens it
+// when the cache generation changes (file reloaded or relaid out). If the
+// file cannot be opened any more, it closes renderStopped and quits. Besides
+// its channels it only uses d.path, d.cache and stateless helpers.
+func (d *DocumentViewer) renderWorker(stopChan <-chan struct{}) {
+	var doc *fitz.Document
+	docGen := -1
+	defer func() {
+		if doc != nil {
+			doc.Close()
+		}
+	}()
+
+	// openDoc returns the worker's document handle for the job's generation.
+	openDoc := func(job renderJob) (*fitz.Document, error) {
+		if doc != nil && docGen == job.gen {
+			return doc, nil
+		}
+		if doc != nil {
+			doc.Close()
+			doc = nil
+		}
+		newDoc, err := fitz.New(d.path)
+		if err != nil {
+			return nil, err
+		}
+		if job.layoutWidth > 0 {
+			layoutHTML(newDoc, job.layoutWidth)
+		}
+		doc, docGen = newDoc, job.gen
+		return doc, nil
+	}
+
+	var urgent, prefetch []renderJob
+	failures := 0 // consecutive failures to open the document
+	for {
+		// Newer batches replace older ones
+		select {
+		case <-stopChan:
+			return
+		case urgent = <-d.renderJobs:
+			continue
+		case prefetch = <-d.prefetchJobs:
+			continue
+		default:
+		}
+
+		var job renderJob
+		switch {
+		case len(urgent) > 0:
+			job, urgent = urgent[0], urgent[1:]
+		case len(prefetch) > 0:
+			job, prefetch = prefetch[0], prefetch[1:]
+		default:
+			select {
+			case <-stopChan:
+				return
+			case urgent = <-d.renderJobs:
+			case prefetch = <-d.prefetchJobs:
+			}
+			continue
+		}
+
+		if job.gen == d.cache.generation() {
+			jobDoc, err := openDoc(job)
+			if err != nil {
+				failures++
+				if failures == openRetries {
+					close(d.renderStopped)
+					return
+				}
+				// The file may be half written (LaTeX is rebuilding it), so
+				// the failure is not cached: the redraw after a pause asks
+				// for the pages again, and the worker tries to open it again
+				urgent, prefetch = nil, nil
+				select {
+				case <-stopChan:
+					return
+				case <-time.After(openRetryDelay):
+				}
+			} else {
+				failures = 0
+				d.runRenderJob(jobDoc, job)
+			}
+		}
+		if job.urgent && len(urgent) == 0 {
+			select {
+			case d.renderDone <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// runRenderJob does one job on the worker's document handle. Pages that
+// cannot be rendered are cached as failed.
+func (d *DocumentViewer) runRenderJob(doc *fitz.Document, job renderJob) {
+	if job.detect {
+		if _, known := d.cache.visualContent(job.key.page); known {
+			return
+		}
+		visual := d.detectVisualContent(doc, job.key.page)
+		d.cache.putVisualContent(job.key.page, visual, job.gen)
+		return
+	}
+
+	if d.cache.has(job.key) {
+		return
+	}
+	if job.key.page >= doc.NumPage() {
+		d.cache.putFailed(job.key, fitz.ErrPageMissing, job.gen)
+		return
+	}
+	img, err := doc.ImageDPI(job.key.page, job.key.dpi)
+	if err != nil {
+		d.cache.putFailed(job.key, err, job.gen)
+		return
+	}
+	d.cache.put(job.key, applyDarkMode(img, job.key.darkMode), job.gen)
+}
@@ -0,0 +1,451 @@
+package main
+
+/*
+#include <stdlib.h>
+
+typedef struct { float x, y; } dv_point;
+typedef struct { dv_point ul, ur, ll, lr; } dv_quad;
+
+extern void *fz_load_page(void *ctx, void *doc, int number);
+extern void fz_drop_page(void *ctx, void *page);
+extern int fz_search_page(void *ctx, void *page, const char *needle, int *hit_mark, dv_quad *hit_bbox, int hit_max);
+
+#include "fitzguard.h"
+
+// dv_load_page loads a page into *page, to be freed with fz_drop_page.
+static int dv_load_page(void *ctx, void *doc, int number, void **page) {
+	dv_try(ctx) {
+		*page = fz_load_page(ctx, doc, number);
+	}
+	dv_catch(ctx) {
+		fz_ignore_error(ctx);
+		return dv_failed;
+	}
+	return 0;
+}
+
+// dv_search_page runs fz_search_page and stores the number of quads found
+// in *count.
+static int dv_search_page(void *ctx, void *page, const char *needle, int *hit_mark, dv_quad *hit_bbox, int hit_max, int *count) {
+	*count = 0;
+	dv_try(ctx) {
+		*count = fz_search_page(ctx, page, needle, hit_mark, hit_bbox, hit_max);
+	}
+	dv_catch(ctx) {
+		fz_ignore_error(ctx);
+		return dv_failed;
+	}
+	return 0;
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"regexp"
+	"regexp/syntax"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+	"unsafe"
+
+	"github.com/gen2brain/go-fitz"
+)
+
+// Search queries are matched with one compiled regular expression, shared
+// by the page search and the highlights in text views, so they always
+// agree. A query is literal text unless it starts with searchRegexPrefix,
+// and it ignores case unless it contains an upper case letter (smart case).
+// In whole-word mode, toggled with Ctrl-W in the search prompt or
+// ":set wholeword", matches must not be part of a longer word. Both the
+// query and the page text are folded first (see foldText).
+//
+// The result is a list of occurrences, which n/N step through. Where each
+// one is on the page comes from MuPDF's text search, given the text
+// matched; its boxes are painted into the page image, the current
+// occurrence in a colour of its own.
+
+// searchRegexPrefix marks a query as a regular expression (Go syntax).
+const searchRegexPrefix = "re:"
+
+// compileSearch compiles a search query into the expression it matches in
+// text folded with fold.
+func compileSearch(query string, fold textFold) (*regexp.Regexp, error) {
+	pattern, isRegex := strings.CutPrefix(query, searchRegexPrefix)
+	pattern = foldText(pattern, fold, false).text
+	if !isRegex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil || hasUpperCase(pattern, isRegex) {
+		return re, err
+	}
+	return regexp.Compile("(?i)" + pattern)
+}
+
+// hasUpperCase reports whether a query asks for case-sensitive matching.
+// Escapes like \D or \S in a regular expression do not count.
+func hasUpperCase(pattern string, isRegex bool) bool {
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			escaped = false
+		case isRegex && r == '\\':
+			escaped = true
+		case unicode.IsUpper(r):
+			return true
+		}
+	}
+	return false
+}
+
+// searchMatcher is a search as the search worker and the text views match
+// it.
+type searchMatcher struct {
+	re        *regexp.Regexp
+	wholeWord bool
+	fold      textFold
+}
+
+// searchMatcher returns the current search; its re is nil when there is
+// none.
+func (d *DocumentViewer) searchMatcher() searchMatcher {
+	return searchMatcher{re: d.searchRe, wholeWord: d.searchWholeWord, fold: d.searchFold(d.searchQuery)}
+}
+
+// searchFold returns how a search for query folds text. A hyphen in the
+// query keeps the hyphens of hyphenated line breaks, which then may be
+// compounds the query spells out.
+func (d *DocumentViewer) searchFold(query string) textFold {
+	return textFold{ligatures: true, diacritics: !d.searchMatchDiacritics, hyphens: strings.Contains(query, "-")}
+}
+
+// searchMatches returns the byte ranges of the search matches in text.
+func (d *DocumentViewer) searchMatches(text string) [][]int {
+	if d.searchRe == nil {
+		return nil
+	}
+	return d.searchMatcher().matches(text)
+}
+
+// matches returns the byte ranges of the matches in text.
+func (m searchMatcher) matches(text string) [][]int {
+	folded := foldText(text, m.fold, false)
+	var found [][]int
+	if m.wholeWord && !looksBehind(m.re) {
+		found = m.wholeWords(folded.text)
+	} else {
+		for _, r := range m.re.FindAllStringIndex(folded.text, -1) {
+			if r[0] == r[1] {
+				continue // empty match of a regular expression
+			}
+			if m.wholeWord && !isWholeWord(folded.text, r[0], r[1]) {
+				continue
+			}
+			found = append(found, r)
+		}
+	}
+	if len(found) == 0 {
+		return nil
+	}
+
+	// Map them back to the original text, now that it is worth it
+	folded = foldText(text, m.fold, true)
+	matches := make([][]int, len(found))
+	for i, r := range found {
+		start, end := folded.origRange(r[0], r[1])
+		matches[i] = []int{start, end}
+	}
+	return matches
+}
+
+// wholeWords returns the byte ranges of the whole-word matches in text.
+// After a match that is part of a word, a match starting one character on
+// may still be a whole word, so the search goes on from there. It matches
+// the rest of text as if it started there, which only gives the right
+// matches for expressions that do not look behind them (see looksBehind).
+func (m searchMatcher) wholeWords(text string) [][]int {
+	var found [][]int
+	for pos := 0; pos < len(text); {
+		r := m.re.FindStringIndex(text[pos:])
+		if r == nil {
+			break
+		}
+		start, end := pos+r[0], pos+r[1]
+		if start == end || !isWholeWord(text, start, end) {
+			_, size := utf8.DecodeRuneInString(text[start:])
+			pos = start + max(size, 1)
+			continue
+		}
+		found = append(found, []int{start, end})
+		pos = end
+	}
+	return found
+}
+
+// looksBehind reports whether re has an assertion about the text before a
+// position (^, \A, \b, \B), which matching a slice of the text would get
+// wrong at its start.
+func looksBehind(re *regexp.Regexp) bool {
+	parsed, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return true
+	}
+	var walk func(*syntax.Regexp) bool
+	walk = func(r *syntax.Regexp) bool {
+		switch r.Op {
+		case syntax.OpBeginLine, syntax.OpBeginText, syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+			return true
+		}
+		return slices.ContainsFunc(r.Sub, walk)
+	}
+	return walk(parsed)
+}
+
+// isWholeWord reports whether text[start:end] is not preceded or followed
+// by a letter, digit or underscore.
+func isWholeWord(text string, start, end int) bool {
+	isWord := func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+	}
+	if r, size := utf8.DecodeLastRuneInString(text[:start]); size > 0 && isWord(r) {
+		return false
+	}
+	if r, size := utf8.DecodeRuneInString(text[end:]); size > 0 && isWord(r) {
+		return false
+	}
+	return true
+}
+
+// searchLabel returns the search as shown in the status bar.
+func (d *DocumentViewer) searchLabel() string {
+	if d.searchWholeWord {
+		return d.searchQuery + " (word)"
+	}
+	return d.searchQuery
+}
+
+// searchIndicator returns the search part of the status bar.
+func (d *DocumentViewer) searchIndicator() string {
+	switch {
+	case d.searchQuery == "":
+		return ""
+	case d.searchRunning():
+		hits := "hits"
+		if len(d.searchHits) == 1 {
+			hits = "hit"
+		}
+		return fmt.Sprintf(" [/%s: searching %d/%d, %d %s]", d.searchLabel(), d.searchScanned, len(d.textPages), len(d.searchHits), hits)
+	case len(d.searchHits) > 0:
+		return fmt.Sprintf(" [/%s: %d/%d]", d.searchLabel(), d.searchHitIdx+1, len(d.searchHits))
+	}
+	return fmt.Sprintf(" [/%s: no matches]", d.searchLabel())
+}
+
+// maxSearchQuads bounds the boxes MuPDF's search returns for one needle on
+// one page.
+const maxSearchQuads = 512
+
+// searchHit is one occurrence of the search.
+type searchHit struct {
+	page       int        // PDF page
+	start, end int        // byte range of the match in the text of the page
+	boxes      []pageRect // areas it covers, one per line; none if MuPDF did not find it
+}
+
+// pageRect is an area of a page in page coordinates (points).
+type pageRect struct {
+	x0, y0, x1, y1 float64
+}
+
+// pageSearchHits turns the matches in the text of a page into occurrences,
+// with their boxes found on doc (none if doc is nil).
+func pageSearchHits(doc *fitz.Document, pdfPage int, text string, matches [][]int) []searchHit {
+	if len(matches) == 0 {
+		return nil
+	}
+	var ctx, page unsafe.Pointer
+	if doc != nil {
+		var docPtr unsafe.Pointer
+		ctx, docPtr = fitzPointers(doc)
+		if C.dv_load_page(ctx, docPtr, C.int(pdfPage), &page) != 0 {
+			page = nil
+		}
+		if page != nil {
+			defer C.fz_drop_page(ctx, page)
+		}
+	}
+
+	// MuPDF finds every occurrence of a needle, ignoring case and the
+	// layout; the n-th of them is the n-th occurrence of the same text here,
+	// with case and layout folded the same way
+	layout := foldText(text, foldLayout, true)
+	found := make(map[string][][]pageRect)
+	hits := make([]searchHit, 0, len(matches))
+	for _, m := range matches {
+		needle := foldText(text[m[0]:m[1]], foldLayout, false).text
+		boxes, ok := found[needle]
+		if !ok && page != nil {
+			boxes = searchPage(ctx, page, needle)
+			found[needle] = boxes
+		}
+		hit := searchHit{page: pdfPage, start: m[0], end: m[1]}
+		if n := occurrenceIndex(layout.text, needle, layout.origIndex(m[0])); n < len(boxes) {
+			hit.boxes = boxes[n]
+		}
+		hits = append(hits, hit)
+	}
+	return hits
+}
+
+// searchPage runs MuPDF's text search for needle on a loaded page and
+// returns the boxes of each occurrence (none if the search fails).
+func searchPage(ctx, page unsafe.Pointer, needle string) [][]pageRect {
+	cNeedle := C.CString(needle)
+	defer C.free(unsafe.Pointer(cNeedle))
+	marks := make([]C.int, maxSearchQuads)
+	quads := make([]C.dv_quad, maxSearchQuads)
+	var count C.int
+	if C.dv_search_page(ctx, page, cNeedle, &marks[0], &quads[0], C.int(maxSearchQuads), &count) != 0 {
+		return nil
+	}
+	n := int(count)
+
+	var hits [][]pageRect
+	for i := 0; i < n; i++ {
+		q := quads[i]
+		xs := []float64{float64(q.ul.x), float64(q.ur.x), float64(q.ll.x), float64(q.lr.x)}
+		ys := []float64{float64(q.ul.y), float64(q.ur.y), float64(q.ll.y), float64(q.lr.y)}
+		box := pageRect{slices.Min(xs), slices.Min(ys), slices.Max(xs), slices.Max(ys)}
+		// A mark starts a new occurrence; further quads are its other lines
+		if marks[i] != 0 || len(hits) == 0 {
+			hits = append(hits, nil)
+		}
+		hits[len(hits)-1] = append(hits[len(hits)-1], box)
+	}
+	return hits
+}
+
+// occurrenceIndex returns how many occurrences of needle in text come
+// before the one at offset, ignoring case the way MuPDF does: letter by
+// letter by their lower case, not with Unicode case folding as (?i), which
+// also equates letters such as ſ and s.
+func occurrenceIndex(text, needle string, offset int) int {
+	if needle == "" {
+		return 0
+	}
+	// Lower case can take more or fewer bytes: offset moves with it
+	offset = len(strings.ToLower(text[:offset]))
+	text, needle = strings.ToLower(text), strings.ToLower(needle)
+	n := 0
+	for i := 0; ; n++ {
+		j := strings.Index(text[i:], needle)
+		if j < 0 || i+j >= offset {
+			return n
+		}
+		i += j + len(needle)
+	}
+}
+
+// paintSearchHits paints the boxes of the occurrences on a page into its
+// image, rendered at dpi; origin is the page's bounds in points.
+func (d *DocumentViewer) paintSearchHits(dst *image.RGBA, pdfPage int, origin image.Rectangle, dpi float64) {
+	scale := dpi / 72.0
+	bounds := dst.Bounds()
+	for i, hit := range d.searchHits {
+		if hit.page != pdfPage {
+			continue
+		}
+		fill := color.RGBA{255, 220, 0, 255} // yellow
+		alpha := uint8(96)
+		if i == d.searchHitIdx {
+			fill = color.RGBA{255, 110, 0, 255} // orange
+			alpha = 128
+		}
+		for _, b := range hit.boxes {
+			r := image.Rect(
+				bounds.Min.X+int((b.x0-float64(origin.Min.X))*scale),
+				bounds.Min.Y+int((b.y0-float64(origin.Min.Y))*scale),
+				bounds.Min.X+int((b.x1-float64(origin.Min.X))*scale+0.5),
+				bounds.Min.Y+int((b.y1-float64(origin.Min.Y))*scale+0.5),
+			).Intersect(bounds)
+			draw.DrawMask(dst, r, &image.Uniform{fill}, image.Point{}, &image.Uniform{color.Alpha{alpha}}, image.Point{}, draw.Over)
+		}
+	}
+}
+
+// searchMarks identifies the search boxes painted on page images: they
+// change with the search, the current occurrence and, while the search
+// runs, the occurrences found.
+func (d *DocumentViewer) searchMarks() string {
+	return fmt.Sprintf("%d/%d/%d", d.searchID, d.searchHitIdx, len(d.searchHits))
+}
+
+// hasSearchHits reports whether a page has occurrences of the search.
+func (d *DocumentViewer) hasSearchHits(pdfPage int) bool {
+	for _, hit := range d.searchHits {
+		if hit.page == pdfPage && len(hit.boxes) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// pageOnScreen reports whether a page is the current one, or the second
+// page shown in dual-page mode.
+func (d *DocumentViewer) pageOnScreen(pdfPage int) bool {
+	return pdfPage == d.textPages[d.currentPage] ||
+		d.dualPageMode != "" && d.currentPage+1 < len(d.textPages) && pdfPage == d.textPages[d.currentPage+1]
+}
+
+// showSearchHit goes to the current occurrence: to its page unless that is
+// on screen, then scrolled so the occurrence is in view.
+func (d *DocumentViewer) showSearchHit() {
+	hit := d.searchHits[d.searchHitIdx]
+	if !d.pageOnScreen(hit.page) {
+		d.recordJump()
+		d.jumpToPage(hit.page + 1)
+	}
+	if len(hit.boxes) == 0 || d.dualPageMode != "" {
+		return
+	}
+
+	// The area the page is shown in, as laid out by displayImagePage and
+	// displayContinuous
+	termWidth, termHeight := d.getTerminalSize()
+	height := termHeight - 3
+	if d.continuous {
+		height = termHeight - 2
+	}
+	dpi, err := d.pageDPI(hit.page, termWidth, height)
+	if err != nil {
+		return
+	}
+	origin, err := d.doc.Bound(hit.page)
+	if err != nil {
+		return
+	}
+	pixelsPerChar, pixelsPerLine := d.getTerminalCellSize()
+	viewW := int(float64(termWidth-1) * pixelsPerChar)
+	viewH := int(float64(height) * pixelsPerLine)
+	box := hit.boxes[0]
+	x := int((box.x0 - float64(origin.Min.X)) * dpi / 72)
+	y := int((box.y0 - float64(origin.Min.Y)) * dpi / 72)
+	yEnd := int((box.y1 - float64(origin.Min.Y)) * dpi / 72)
+
+	// Rendering clamps the offsets to the page
+	if d.continuous {
+		d.viewY = max(y-viewH/3, 0)
+		d.scrollContinuous(0)
+	} else if y < d.viewY || yEnd > d.viewY+viewH {
+		d.viewY = max(y-viewH/3, 0)
+	}
+	if x < d.viewX || x > d.viewX+viewW {
+		d.viewX = max(x-viewW/3, 0)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Page text from MuPDF is not what was written: LaTeX output has ligatures
+// (ﬁ, ﬂ), soft hyphens and words split across lines as "equa-\ntion".
+// Searches are matched against folded text, and so is the query, where
+// those are undone: hyphenated line breaks are joined (keeping the hyphen
+// if the query has one, for compounds like "well-\nknown"), white space runs
+// (line breaks included) become one space, ligatures are expanded and,
+// unless ":set diacritics match", diacritics are dropped (Erdős → Erdos).
+// Every byte of folded text remembers the part of the original text it
+// came from, so matches can be mapped back for highlighting.
+
+// textFold selects what foldText does besides joining hyphenated line
+// breaks and collapsing white space, which MuPDF's own search does too.
+type textFold struct {
+	ligatures  bool // expand ligatures: ﬁ → fi
+	diacritics bool // drop diacritics: é → e
+	hyphens    bool // keep the hyphen of hyphenated line breaks: well-\nknown → well-known
+}
+
+// foldLayout only undoes the layout, like MuPDF's search.
+var foldLayout = textFold{}
+
+// foldedText is text as matched by searches.
+type foldedText struct {
+	text       string
+	start, end []int // byte range of the original text each byte of text comes from; nil unless recorded
+}
+
+// origRange maps the byte range [start, end) of the folded text back to
+// the original text.
+func (f foldedText) origRange(start, end int) (int, int) {
+	return f.start[start], f.end[end-1]
+}
+
+// origIndex returns the first byte of the folded text that comes from
+// original text at or after offset.
+func (f foldedText) origIndex(offset int) int {
+	return sort.SearchInts(f.start, offset)
+}
+
+// foldText folds text for matching. With record, the result maps each of
+// its bytes back to the original text.
+func foldText(text string, fold textFold, record bool) foldedText {
+	var f foldedText
+	var b strings.Builder
+	emit := func(s string, start, end int) {
+		b.WriteString(s)
+		if record {
+			for range len(s) {
+				f.start = append(f.start, start)
+				f.end = append(f.end, end)
+			}
+		}
+	}
+
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case r == '\u00ad': // soft hyphen
+			i += size
+		case r == '-' && hyphenatedBreak(text, i):
+			if fold.hyphens {
+				emit("-", i, i+size)
+			}
+			i = skipSpace(text, i+size)
+		case unicode.IsSpace(r):
+			end := skipSpace(text, i)
+			emit(" ", i, end)
+			i = end
+		case fold.diacritics && unicode.Is(unicode.Mn, r):
+			// A combining mark: the letter before it covers it now
+			if record && len(f.end) > 0 {
+				last := f.start[len(f.start)-1]
+				for k := len(f.end) - 1; k >= 0 && f.start[k] == last; k-- {
+					f.end[k] = i + size
+				}
+			}
+			i += size
+		default:
+			out := ""
+			if fold.ligatures {
+				out = ligatures[r]
+			}
+			if base, ok := diacriticBase[r]; ok && fold.diacritics {
+				out = string(base)
+			}
+			if out == "" {
+				out = text[i : i+size]
+			}
+			emit(out, i, i+size)
+			i += size
+		}
+	}
+	f.text = b.String()
+	return f
+}
+
+// hyphenatedBreak reports whether the hyphen at text[i] ends a line in the
+// middle of a word.
+func hyphenatedBreak(text string, i int) bool {
+	before, _ := utf8.DecodeLastRuneInString(text[:i])
+	if !unicode.IsLetter(before) {
+		return false
+	}
+	rest := text[i+1:]
+	lineEnd := strings.IndexByte(rest, '\n')
+	if lineEnd < 0 || strings.TrimSpace(rest[:lineEnd]) != "" {
+		return false
+	}
+	after, _ := utf8.DecodeRuneInString(strings.TrimLeftFunc(rest[lineEnd:], unicode.IsSpace))
+	return unicode.IsLetter(after)
+}
+
+// skipSpace returns the end of the white space starting at text[i].
+func skipSpace(text string, i int) int {
+	for i < len(text) {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		i += size
+	}
+	return i
+}
+
+// ligatures maps the Unicode ligatures to their letters.
+var ligatures = map[rune]string{
+	'ﬀ': "ff",
+	'ﬁ': "fi",
+	'ﬂ': "fl",
+	'ﬃ': "ffi",
+	'ﬄ': "ffl",
+	'ﬅ': "st",
+	'ﬆ': "st",
+}
+
+// diacriticBase maps the Latin letters with diacritics to the letter
+// without them.
+var diacriticBase = map[rune]rune{}
+
+func init() {
+	for base, letters := range map[rune]string{
+		'a': "àáâãäåāăąǎ", 'A': "ÀÁÂÃÄÅĀĂĄǍ",
+		'c': "çćĉċč", 'C': "ÇĆĈĊČ",
+		'd': "ďđ", 'D': "ĎĐ",
+		'e': "èéêëēĕėęě", 'E': "ÈÉÊËĒĔĖĘĚ",
+		'g': "ĝğġģ", 'G': "ĜĞĠĢ",
+		'h': "ĥħ", 'H': "ĤĦ",
+		'i': "ìíîïĩīĭįıǐ", 'I': "ÌÍÎÏĨĪĬĮİǏ",
+		'j': "ĵ", 'J': "Ĵ",
+		'k': "ķ", 'K': "Ķ",
+		'l': "ĺļľŀł", 'L': "ĹĻĽĿŁ",
+		'n': "ñńņňŉ", 'N': "ÑŃŅŇ",
+		'o': "òóôõöøōŏőǒ", 'O': "ÒÓÔÕÖØŌŎŐǑ",
+		'r': "ŕŗř", 'R': "ŔŖŘ",
+		's': "śŝşšș", 'S': "ŚŜŞŠȘ",
+		't': "ţťŧț", 'T': "ŢŤŦȚ",
+		'u': "ùúûüũūŭůűųǔ", 'U': "ÙÚÛÜŨŪŬŮŰŲǓ",
+		'w': "ŵ", 'W': "Ŵ",
+		'y': "ýÿŷ", 'Y': "ÝŸŶ",
+		'z': "źżž", 'Z': "ŹŻŽ",
+	} {
+		for _, r := range letters {
+			diacriticBase[r] = base
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// The search results overlay lists every occurrence of the search with its
+// page and the text around it, to see at a glance where a term is defined
+// and where it is only used. Typing after / narrows the list down to the
+// results whose page or text contains the filter; Enter goes to the
+// occurrence.
+
+// snippetContext is how many characters around a match are kept for its
+// snippet; the overlay shows as many of them as fit.
+const snippetContext = 150
+
+// searchResult is one line of the search results overlay.
+type searchResult struct {
+	hit    int    // index in d.searchHits
+	page   string // page label
+	before string // snippet text before the match, on one line
+	match  string
+	after  string
+}
+
+// listSearchResults returns the lines for the occurrences found so far,
+// from the one with index from on.
+func (d *DocumentViewer) listSearchResults(from int) []searchResult {
+	results := make([]searchResult, 0, len(d.searchHits)-from)
+	texts := make(map[int]string)
+	for i := from; i < len(d.searchHits); i++ {
+		hit := d.searchHits[i]
+		text, ok := texts[hit.page]
+		if !ok {
+			text, _ = d.pageText(hit.page)
+			texts[hit.page] = text
+		}
+		r := searchResult{hit: i, page: d.pageLabel(hit.page)}
+		if hit.end <= len(text) {
+			before := []rune(oneLine(text[:hit.start]))
+			after := []rune(oneLine(text[hit.end:]))
+			r.before = string(before[max(len(before)-snippetContext, 0):])
+			r.match = oneLine(text[hit.start:hit.end])
+			r.after = string(after[:min(len(after), snippetContext)])
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// oneLine turns runs of white space and control characters into single
+// spaces.
+func oneLine(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		if unicode.IsSpace(r) || !unicode.IsPrint(r) {
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	if space {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+// filterSearchResults returns the results whose page label or snippet
+// contains filter, ignoring case.
+func filterSearchResults(results []searchResult, filter string) []searchResult {
+	if filter == "" {
+		return results
+	}
+	filter = strings.ToLower(filter)
+	var shown []searchResult
+	for _, r := range results {
+		if strings.Contains(strings.ToLower(r.page+" "+r.before+r.match+r.after), filter) {
+			shown = append(shown, r)
+		}
+	}
+	return shown
+}
+
+// showSearchResults lists the occurrences of the search in an overlay and
+// goes to the chosen one. The current occurrence is selected at first.
+// While the search runs, the occurrences it finds are added to the list.
+func (d *DocumentViewer) showSearchResults(inputChan <-chan keyEvent) {
+	d.takeSearchReports()
+	if len(d.searchHits) == 0 && !d.searchRunning() {
+		_, rows := d.getTerminalSize()
+		fmt.Printf("\033[%d;1H\033[K", rows)
+		if d.searchQuery == "" {
+			fmt.Print("No search yet: press / to search (press any key)")
+		} else {
+			fmt.Print("No search results (press any key)")
+		}
+		<-inputChan
+		return
+	}
+
+	results := d.listSearchResults(0)
+	shown := results
+	var filter []rune
+	typing := false // keys go to the filter
+	selected := d.searchHitIdx
+	offset := 0
+
+	for {
+		termWidth, termHeight := d.getTerminalSize()
+		// header (3) + footer (2)
+		listHeight := termHeight - 5
+		if listHeight < 1 {
+			listHeight = 1
+		}
+		if selected >= len(shown) {
+			selected = len(shown) - 1
+		}
+		if selected < 0 {
+			selected = 0
+		}
+		if selected < offset {
+			offset = selected
+		} else if selected >= offset+listHeight {
+			offset = selected - listHeight + 1
+		}
+
+		d.drawSearchResults(results, shown, string(filter), typing, selected, offset, termWidth, listHeight)
+
+		var ev keyEvent
+		select {
+		case ev = <-inputChan:
+		case r := <-d.searchResults:
+			// New results go at the end, so the selection stays put
+			d.addSearchReport(r)
+			results = append(results, d.listSearchResults(len(results))...)
+			shown = filterSearchResults(results, string(filter))
+			continue
+		}
+		if typing {
+			switch ev.key {
+			case keyEnter:
+				typing = false
+			case keyEscape: // drop the filter
+				typing = false
+				filter = nil
+			case keyBackspace:
+				if len(filter) > 0 {
+					filter = filter[:len(filter)-1]
+				}
+			default:
+				if r := ev.text(); r != 0 {
+					filter = append(filter, r)
+				}
+			}
+			// Keep the selection on the same result if it is still shown
+			hit := -1
+			if len(shown) > 0 {
+				hit = shown[selected].hit
+			}
+			shown = filterSearchResults(results, string(filter))
+			selected = 0
+			for i, r := range shown {
+				if r.hit == hit {
+					selected = i
+				}
+			}
+			continue
+		}
+
+		switch ev.char() {
+		case 'q', 's', 27: // close
+			return
+		case 'j':
+			if selected < len(shown)-1 {
+				selected++
+			}
+		case 'k':
+			if selected > 0 {
+				selected--
+			}
+		case 'J':
+			selected = min(selected+listHeight, len(shown)-1)
+		case 'K':
+			selected = max(selected-listHeight, 0)
+		case 'g':
+			selected = 0
+		case 'G':
+			selected = len(shown) - 1
+		case '/':
+			typing = true
+		case 13, 10: // Enter: go to the occurrence
+			if len(shown) > 0 {
+				d.searchHitIdx = shown[selected].hit
+				d.showSearchHit()
+				return
+			}
+		}
+	}
+}
+
+func (d *DocumentViewer) drawSearchResults(results, shown []searchResult, filter string, typing bool, selected, offset, termWidth, listHeight int) {
+	d.clearScreen()
+	p := func(s string) { fmt.Print(s + "\r\n") }
+
+	header := fmt.Sprintf("Search results for /%s (%d)", d.searchLabel(), len(results))
+	if filter != "" {
+		header += fmt.Sprintf(", %d matching %q", len(shown), filter)
+	}
+	if d.searchRunning() {
+		header += fmt.Sprintf(", searched %d/%d pages", d.searchScanned, len(d.textPages))
+	}
+	p(strings.Repeat("=", termWidth))
+	p(header)
+	p(strings.Repeat("=", termWidth))
+
+	labelWidth := 0
+	for _, r := range shown {
+		labelWidth = max(labelWidth, len([]rune(r.page)))
+	}
+	end := min(offset+listHeight, len(shown))
+	for i := offset; i < end; i++ {
+		r := shown[i]
+		prefix := fmt.Sprintf("  %*s  ", labelWidth, r.page)
+		before, match, after := fitSnippet(r, termWidth-len([]rune(prefix))-1)
+		// The match stands out in yellow, the current occurrence in bold
+		line := prefix + before + "\033[33m" + match + "\033[39m" + after
+		switch {
+		case i == selected:
+			fmt.Print("\033[7m" + line + "\033[0m\r\n") // reverse video
+		case r.hit == d.searchHitIdx:
+			fmt.Print("\033[1m" + line + "\033[0m\r\n")
+		default:
+			p(line)
+		}
+	}
+	switch {
+	case len(shown) > 0:
+	case filter != "":
+		p("")
+		p("  No results match the filter.")
+	default:
+		p("")
+		p("  Searching...")
+	}
+
+	_, rows := d.getTerminalSize()
+	fmt.Printf("\033[%d;1H", rows)
+	if typing {
+		fmt.Print("\033[?25h") // show cursor
+		fmt.Print("Filter: " + filter)
+		return
+	}
+	fmt.Print("\033[?25l")
+	fmt.Print("\033[2m  j/k: Move  Enter: Go to  /: Filter  q/Esc: Close\033[0m")
+}
+
+// fitSnippet cuts the snippet of a result to width characters, keeping
+// about a third of the room before the match.
+func fitSnippet(r searchResult, width int) (before, match, after string) {
+	b, m, a := []rune(r.before), []rune(r.match), []rune(r.after)
+	if len(m) >= width {
+		return "", string(m[:max(width-1, 0)]) + "…", ""
+	}
+	room := width - len(m)
+	nb := min(len(b), max(room/3, room-len(a)))
+	na := min(len(a), room-nb)
+	before = string(b[len(b)-nb:])
+	if nb < len(b) && nb > 0 {
+		before = "…" + string(b[len(b)-nb+1:])
+	}
+	after = string(a[:na])
+	if na < len(a) && na > 0 {
+		after = string(a[:na-1]) + "…"
+	}
+	return before, string(m), after
+}
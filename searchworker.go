@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/gen2brain/go-fitz"
+)
+
+// Searches run in a worker goroutine, so that searching a long document
+// never blocks the viewer. The worker goes through the pages in order and
+// streams the occurrences it finds to the main loop, which shows the
+// progress in the status bar and goes to the first occurrence as soon as it
+// is found. ESC, or starting another search, cancels it. Page text comes
+// from the cache, so searching again is fast; like the render worker, the
+// search worker opens a document handle of its own for the rest (pages not
+// extracted yet and the boxes of occurrences).
+
+// searchReportInterval is how often the worker reports its progress.
+const searchReportInterval = 100 * time.Millisecond
+
+// searchJob is one search for the worker.
+type searchJob struct {
+	id          int
+	matcher     searchMatcher
+	pages       []int // PDF pages to search, in order
+	gen         int   // cache generation the job was created for
+	layoutWidth int   // HTML page width to lay the document out with; 0 for none
+}
+
+// searchReport is what the worker found since its last report.
+type searchReport struct {
+	id      int         // searchJob.id
+	scanned int         // pages searched so far
+	hits    []searchHit // new occurrences
+	done    bool        // all pages searched
+}
+
+// beginSearch starts searching for the current search, replacing the
+// results of the last one. With jump, the first occurrence is shown as soon
+// as it is found.
+func (d *DocumentViewer) beginSearch(jump bool) {
+	d.cancelSearch()
+	d.searchHits = nil
+	d.searchHitIdx = 0
+	d.searchScanned = 0
+	d.searchJump = jump
+	if d.searchResults == nil {
+		d.searchResults = make(chan searchReport, 1)
+	}
+
+	d.searchID++
+	job := searchJob{
+		id:      d.searchID,
+		matcher: d.searchMatcher(),
+		pages:   slices.Clone(d.textPages),
+		gen:     d.cache.generation(),
+	}
+	if d.isReflowable {
+		job.layoutWidth = d.htmlPageWidth
+	}
+	d.searchCancel = make(chan struct{})
+	go d.searchWorker(job, d.searchCancel)
+}
+
+// cancelSearch stops the running search, keeping what it found so far.
+func (d *DocumentViewer) cancelSearch() {
+	if d.searchCancel != nil {
+		close(d.searchCancel)
+		d.searchCancel = nil
+	}
+}
+
+// searchRunning reports whether the search worker is still going through
+// the document.
+func (d *DocumentViewer) searchRunning() bool {
+	return d.searchCancel != nil
+}
+
+// restartSearch searches the document again after it changed (reload,
+// relayout), where the occurrences found before may have moved.
+func (d *DocumentViewer) restartSearch() {
+	if d.searchRe != nil {
+		d.beginSearch(false)
+	}
+}
+
+// addSearchReport takes in a report of the search worker. It reports
+// whether the page has to be redrawn; otherwise only the status bar changed.
+func (d *DocumentViewer) addSearchReport(r searchReport) bool {
+	if r.id != d.searchID || !d.searchRunning() {
+		return false // from a cancelled search
+	}
+	first := len(d.searchHits) == 0
+	d.searchHits = append(d.searchHits, r.hits...)
+	d.searchScanned = r.scanned
+	if r.done {
+		d.searchCancel = nil
+	}
+	if first && len(r.hits) > 0 && d.searchJump {
+		d.showSearchHit()
+		return true
+	}
+	for _, hit := range r.hits {
+		if d.pageOnScreen(hit.page) {
+			return true // its box is painted on the page
+		}
+	}
+	return false
+}
+
+// takeSearchReports takes in the reports the search worker has sent and the
+// main loop not received yet, for overlays that show the results.
+func (d *DocumentViewer) takeSearchReports() {
+	for {
+		select {
+		case r := <-d.searchResults:
+			d.addSearchReport(r)
+		default:
+			return
+		}
+	}
+}
+
+// drawSearchProgress updates the status bar while the search runs, unless
+// a message is shown in its place.
+func (d *DocumentViewer) drawSearchProgress() {
+	if d.statusMessage != "" {
+		return
+	}
+	termWidth, termHeight := d.getTerminalSize()
+	fmt.Printf("\033[%d;1H\033[2K", termHeight)
+	if d.dualPageMode != "" && !d.continuous {
+		d.displayDualPageInfo(d.currentPage+1 < len(d.textPages), termWidth, d.statusLabel)
+	} else {
+		d.displayPageInfo(d.textPages[d.currentPage], termWidth, d.statusLabel)
+	}
+	os.Stdout.Sync()
+}
+
+// searchWorker searches the pages of a job and sends what it finds to
+// d.searchResults, until it is done or cancel is closed. When the document
+// is reloaded or laid out again it stops without finishing, since what it
+// found is out of date; the search then starts over (restartSearch).
+// Besides its channels it only uses d.path, d.cache and stateless helpers.
+func (d *DocumentViewer) searchWorker(job searchJob, cancel <-chan struct{}) {
+	var doc *fitz.Document
+	opened := false
+	defer func() {
+		if doc != nil {
+			doc.Close()
+		}
+	}()
+
+	// openDoc returns the worker's document handle, or nil if the file
+	// cannot be opened.
+	openDoc := func() *fitz.Document {
+		if opened {
+			return doc
+		}
+		opened = true
+		newDoc, err := fitz.New(d.path)
+		if err != nil {
+			return nil
+		}
+		if job.layoutWidth > 0 {
+			layoutHTML(newDoc, job.layoutWidth)
+		}
+		doc = newDoc
+		return doc
+	}
+
+	report := searchReport{id: job.id}
+	found := false
+	lastReport := time.Now()
+	for i, page := range job.pages {
+		select {
+		case <-cancel:
+			return
+		default:
+		}
+		if job.gen != d.cache.generation() {
+			return
+		}
+
+		text, ok := d.cache.pageText(page)
+		if !ok {
+			if doc := openDoc(); doc != nil {
+				if t, err := doc.Text(page); err == nil {
+					text = t
+					d.cache.putPageText(page, text, job.gen)
+				}
+			}
+		}
+		if matches := job.matcher.matches(text); len(matches) > 0 {
+			report.hits = append(report.hits, pageSearchHits(openDoc(), page, text, matches)...)
+		}
+		report.scanned = i + 1
+
+		// The first occurrence is reported at once, then the progress now
+		// and then. While the main loop is busy, reports add up.
+		if !found && len(report.hits) > 0 || time.Since(lastReport) >= searchReportInterval {
+			select {
+			case d.searchResults <- report:
+				found = found || len(report.hits) > 0
+				report.hits = nil
+				lastReport = time.Now()
+			default:
+			}
+		}
+	}
+
+	report.done = true
+	select {
+	case d.searchResults <- report:
+	case <-cancel:
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// maxStateEntries bounds the state file; least recently opened documents are dropped first.
+const maxStateEntries = 500
+
+// docState is the saved reading position and view settings of one document.
+type docState struct {
+	Path          string     `json:"path"`
+	Fingerprint   string     `json:"fingerprint"`
+	Page          int        `json:"page"` // 0-indexed PDF page
+	FitMode       string     `json:"fit_mode"`
+	DarkMode      string     `json:"dark_mode"`
+	ScaleFactor   float64    `json:"scale_factor"`
+	DualPageMode  string     `json:"dual_page_mode"`
+	Continuous    bool       `json:"continuous"`
+	HTMLPageWidth int        `json:"html_page_width"`
+	Bookmarks     []bookmark `json:"bookmarks,omitempty"`
+	LastOpened    time.Time  `json:"last_opened"`
+}
+
+// stateStore is the on-disk collection of docStates, keyed by absolute path.
+type stateStore struct {
+	Documents map[string]*docState `json:"documents"`
+}
+
+// stateFilePath returns $XDG_STATE_HOME/docviewer/state.json
+// (default ~/.local/state/docviewer/state.json).
+func stateFilePath() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(homeDir, ".local", "state")
+	}
+	return filepath.Join(dir, "docviewer", "state.json")
+}
+
+func loadStateStore() *stateStore {
+	store := &stateStore{Documents: make(map[string]*docState)}
+	path := stateFilePath()
+	if path == "" {
+		return store
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return store
+	}
+	if err := json.Unmarshal(data, store); err != nil || store.Documents == nil {
+		store.Documents = make(map[string]*docState)
+	}
+	return store
+}
+
+// save writes the store atomically (temp file + rename) so that concurrent
+// viewers never leave a half-written file behind.
+func (s *stateStore) save() error {
+	path := stateFilePath()
+	if path == "" {
+		return fmt.Errorf("cannot determine state directory")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// Drop the least recently opened documents
+	if len(s.Documents) > maxStateEntries {
+		keys := make([]string, 0, len(s.Documents))
+		for k := range s.Documents {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return s.Documents[keys[i]].LastOpened.After(s.Documents[keys[j]].LastOpened)
+		})
+		for _, k := range keys[maxStateEntries:] {
+			delete(s.Documents, k)
+		}
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "state-*.json")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	tmp.Close()
+	return os.Rename(tmp.Name(), path)
+}
+
+// lookup finds the state for a document, first by path, then by fingerprint
+// for files that were moved or renamed since they were last opened.
+func (s *stateStore) lookup(absPath, fingerprint string) *docState {
+	if st, ok := s.Documents[absPath]; ok {
+		return st
+	}
+	if fingerprint == "" {
+		return nil
+	}
+	var best *docState
+	for _, st := range s.Documents {
+		if st.Fingerprint == fingerprint && (best == nil || st.LastOpened.After(best.LastOpened)) {
+			best = st
+		}
+	}
+	return best
+}
+
+// fileFingerprint hashes the size plus the first and last 64 KiB of a file,
+// or all of it up to 128 KiB. Cheap enough for large scans, and stable
+// across moves and renames.
+func fileFingerprint(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return ""
+	}
+
+	const chunk = 64 * 1024
+	h := sha256.New()
+	fmt.Fprintf(h, "%d:", info.Size())
+	if info.Size() <= 2*chunk {
+		// Small file: hash all of it, the two chunks would leave a gap
+		if _, err := io.Copy(h, f); err != nil {
+			return ""
+		}
+		return fmt.Sprintf("%x", h.Sum(nil)[:16])
+	}
+	if _, err := io.CopyN(h, f, chunk); err != nil {
+		return ""
+	}
+	if _, err := f.Seek(-chunk, io.SeekEnd); err == nil {
+		io.Copy(h, f)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)[:16])
+}
+
+// restoreState applies saved view settings. Call before the document is
+// laid out; the saved page is returned for restorePosition.
+func (d *DocumentViewer) restoreState() *docState {
+	absPath, _ := filepath.Abs(d.path)
+	d.fingerprint = fileFingerprint(d.path)
+	st := loadStateStore().lookup(absPath, d.fingerprint)
+	if st == nil {
+		return nil
+	}
+
+	switch st.FitMode {
+	case "height", "width", "auto":
+		d.fitMode = st.FitMode
+	}
+	switch st.DarkMode {
+	case "", "smart", "invert":
+		d.darkMode = st.DarkMode
+	}
+	switch st.DualPageMode {
+	case "", "vertical", "horizontal":
+		d.dualPageMode = st.DualPageMode
+	}
+	d.continuous = st.Continuous && d.dualPageMode == ""
+	if st.ScaleFactor >= 0.1 && st.ScaleFactor <= 2.0 {
+		d.scaleFactor = st.ScaleFactor
+	}
+	if st.HTMLPageWidth >= 200 && st.HTMLPageWidth <= 3000 {
+		d.htmlPageWidth = st.HTMLPageWidth
+	}
+	d.bookmarks = st.Bookmarks
+	return st
+}
+
+// restorePosition moves to the saved page, clamped to the current document
+// length in case it got shorter since it was last opened.
+func (d *DocumentViewer) restorePosition(st *docState) {
+	if st == nil || st.Page < 0 {
+		return
+	}
+	d.jumpToPage(st.Page + 1)
+}
+
+// saveState records the current page, view settings and bookmarks for this document.
+func (d *DocumentViewer) saveState() {
+	if len(d.textPages) == 0 {
+		return
+	}
+	absPath, _ := filepath.Abs(d.path)
+	store := loadStateStore()
+
+	// A moved or renamed file leaves its old entry behind; drop it
+	if old := store.lookup(absPath, d.fingerprint); old != nil && old.Path != absPath {
+		if _, err := os.Stat(old.Path); os.IsNotExist(err) {
+			delete(store.Documents, old.Path)
+		}
+	}
+
+	store.Documents[absPath] = &docState{
+		Path:          absPath,
+		Fingerprint:   fileFingerprint(d.path),
+		Page:          d.textPages[d.currentPage],
+		FitMode:       d.fitMode,
+		DarkMode:      d.darkMode,
+		ScaleFactor:   d.scaleFactor,
+		DualPageMode:  d.dualPageMode,
+		Continuous:    d.continuous,
+		HTMLPageWidth: d.htmlPageWidth,
+		Bookmarks:     d.bookmarks,
+		LastOpened:    time.Now(),
+	}
+	store.save()
+}
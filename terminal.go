@@ -3,14 +3,20 @@ package main
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"strings"
 	"syscall"
 	"time"
 	"unsafe"
 
+	"golang.org/x/sys/unix"
 	"golang.org/x/term"
 )
 
+// resizeDebounce is how long the terminal size has to settle before the
+// screen is redrawn for it; windows are resized in many small steps.
+const resizeDebounce = 100 * time.Millisecond
+
 func (d *DocumentViewer) getTerminalSize() (int, int) {
 	// Try stdout first - it's connected to the correct PTY in Kitty splits
 	if width, height, err := term.GetSize(int(os.Stdout.Fd())); err == nil && width > 0 && height > 0 {
@@ -26,8 +32,23 @@ func (d *DocumentViewer) getTerminalSize() (int, int) {
 	return 80, 24 // Fallback default
 }
 
-// detecting Terminal
+// detecting Terminal: by the name it answered the startup probe with, else
+// from the environment. Inside tmux this is the terminal tmux runs in (tmux
+// answers the probe itself); the result is cached, since tmux has to be
+// asked.
 func (d *DocumentViewer) detectTerminalType() string {
+	if d.termType == "" {
+		if d.caps.name != "" && multiplexer() == "" {
+			d.termType = terminalFromName(d.caps.name)
+		}
+		if d.termType == "" || d.termType == "unknown" {
+			d.termType = detectTerminalType()
+		}
+	}
+	return d.termType
+}
+
+func detectTerminalType() string {
 	if termProgram := os.Getenv("TERM_PROGRAM"); termProgram != "" {
 		switch termProgram {
 		case "WezTerm":
@@ -41,7 +62,24 @@ func (d *DocumentViewer) detectTerminalType() string {
 	if os.Getenv("KITTY_WINDOW_ID") != "" || os.Getenv("KITTY_PID") != "" {
 		return "kitty"
 	}
-	term := os.Getenv("TERM")
+	if multiplexer() == "tmux" {
+		// The name the terminal reports (tmux 3.3+), then its TERM
+		for _, format := range []string{"#{client_termtype}", "#{client_termname}"} {
+			out, err := exec.Command("tmux", "display", "-p", format).Output()
+			if err != nil {
+				break
+			}
+			if termType := terminalFromName(strings.TrimSpace(string(out))); termType != "unknown" {
+				return termType
+			}
+		}
+	}
+	return terminalFromName(os.Getenv("TERM"))
+}
+
+// terminalFromName maps a TERM value or terminal name to a terminal type.
+func terminalFromName(name string) string {
+	term := strings.ToLower(name)
 	switch {
 	case strings.Contains(term, "kitty"):
 		return "kitty"
@@ -51,6 +89,10 @@ func (d *DocumentViewer) detectTerminalType() string {
 		return "alacritty"
 	case strings.Contains(term, "wezterm"):
 		return "wezterm"
+	case strings.Contains(term, "iterm"):
+		return "iterm2"
+	case strings.Contains(term, "konsole"):
+		return "konsole"
 	case strings.Contains(term, "xterm"):
 		return "xterm"
 	case strings.Contains(term, "tmux"):
@@ -61,11 +103,63 @@ func (d *DocumentViewer) detectTerminalType() string {
 	return "unknown"
 }
 
+// multiplexer returns "tmux" or "screen" when running inside one, or "".
+func multiplexer() string {
+	switch {
+	case os.Getenv("TMUX") != "":
+		return "tmux"
+	case os.Getenv("STY") != "":
+		return "screen"
+	}
+	return ""
+}
+
+// enablePassthrough lets escape sequences through to the outer terminal in
+// tmux 3.3+, which blocks them by default. The option is set for our pane
+// only. It returns the pane's value before ("" when the pane had none of its
+// own) and whether it changed it, for restorePassthrough.
+func (d *DocumentViewer) enablePassthrough() (string, bool) {
+	if multiplexer() != "tmux" || d.graphicsProtocol(d.detectTerminalType()) == "" {
+		return "", false
+	}
+	out, err := exec.Command("tmux", "show", "-pv", "allow-passthrough").Output()
+	if err != nil {
+		return "", false // tmux before 3.3 has no such option
+	}
+	old := strings.TrimSpace(string(out))
+	if old == "on" || old == "all" {
+		return old, false
+	}
+	if exec.Command("tmux", "set", "-p", "allow-passthrough", "on").Run() != nil {
+		return old, false
+	}
+	return old, true
+}
+
+// restorePassthrough puts the pane's allow-passthrough option back as
+// enablePassthrough found it.
+func restorePassthrough(old string) {
+	if old == "" {
+		exec.Command("tmux", "set", "-pu", "allow-passthrough").Run()
+		return
+	}
+	exec.Command("tmux", "set", "-p", "allow-passthrough", old).Run()
+}
+
 func (d *DocumentViewer) getTerminalCellSize() (float64, float64) {
+	// Images drawn with text cells have a fixed resolution per cell
+	if d.cellRenderMode() != "" {
+		return cellModeWidth, cellModeHeight
+	}
+
 	// Check if terminal dimensions changed (resolution/monitor switch)
 	cols, rows := d.getTerminalSize()
 	if cols != d.lastTermCols || rows != d.lastTermRows {
-		// Terminal changed - invalidate cache and re-detect
+		// Terminal changed - invalidate cache and re-detect. The cell size
+		// may have changed too (font size or monitor switch)
+		if d.lastTermCols != 0 {
+			d.queryCellSize()
+		}
 		d.cellWidth = 0
 		d.cellHeight = 0
 		d.lastTermCols = cols
@@ -84,13 +178,34 @@ func (d *DocumentViewer) getTerminalCellSize() (float64, float64) {
 
 // refreshCellSize forces re-detection of cell size (useful after resolution change)
 func (d *DocumentViewer) refreshCellSize() {
+	d.queryCellSize()
 	d.cellWidth = 0
 	d.cellHeight = 0
 	d.lastTermCols = 0
 	d.lastTermRows = 0
 }
 
-// detectCellSize detects cell size - call before entering raw mode
+// queryCellSize asks a terminal that answered the startup probe for its
+// cell size again. The answer comes in with the keys and is passed to
+// setReportedCellSize; until then the size is detected without it.
+func (d *DocumentViewer) queryCellSize() {
+	if d.caps.cellWidth > 0 {
+		d.cellReportStale = true
+		fmt.Print("\033[16t")
+	}
+}
+
+// setReportedCellSize takes in the cell size the terminal answered with. It
+// reports whether the cell size in use changed, so the page needs a redraw.
+func (d *DocumentViewer) setReportedCellSize(width, height float64) bool {
+	d.caps.cellWidth, d.caps.cellHeight = width, height
+	d.cellReportStale = false
+	oldWidth, oldHeight := d.cellWidth, d.cellHeight
+	d.cellWidth, d.cellHeight = d.detectCellSize()
+	return d.cellWidth != oldWidth || d.cellHeight != oldHeight
+}
+
+// detectCellSize detects cell size without querying the terminal
 func (d *DocumentViewer) detectCellSize() (float64, float64) {
 	// Check for environment variable override first (most reliable for multi-resolution)
 	// Format: DOCVIEWER_CELL_SIZE=WxH (e.g., "12x26")
@@ -101,9 +216,11 @@ func (d *DocumentViewer) detectCellSize() (float64, float64) {
 		}
 	}
 
-	// Try Kitty-specific query first (most accurate)
-	if kw, kh := d.getKittyCellSize(); kw > 0 && kh > 0 {
-		return kw, kh
+	// Then what the terminal last answered (most accurate), unless it is
+	// being asked again
+	reported := d.caps.cellWidth > 0 && d.caps.cellHeight > 0
+	if reported && !d.cellReportStale {
+		return d.caps.cellWidth, d.caps.cellHeight
 	}
 
 	// Try TIOCGWINSZ pixel size
@@ -118,6 +235,11 @@ func (d *DocumentViewer) detectCellSize() (float64, float64) {
 		}
 	}
 
+	// An out of date answer is still closer than a guess
+	if reported {
+		return d.caps.cellWidth, d.caps.cellHeight
+	}
+
 	// Fallback to hardcoded values
 	termType := d.detectTerminalType()
 	switch termType {
@@ -173,61 +295,11 @@ func (d *DocumentViewer) getTerminalPixelSize() (int, int) {
 	return 0, 0
 }
 
-// getKittyCellSize queries Kitty for actual cell size using escape sequence
-// Uses /dev/tty directly for reliable TTY access
-func (d *DocumentViewer) getKittyCellSize() (float64, float64) {
-	if d.detectTerminalType() != "kitty" {
-		return 0, 0
-	}
-
-	// Open /dev/tty directly for reliable TTY access
-	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
-	if err != nil {
-		return 0, 0
-	}
-	defer tty.Close()
-
-	// Save terminal state and set raw mode for reading response
-	fd := int(tty.Fd())
-	oldState, err := term.MakeRaw(fd)
-	if err != nil {
-		return 0, 0
-	}
-	defer term.Restore(fd, oldState)
-
-	// Query cell size: CSI 16 t -> CSI 6 ; height ; width t
-	tty.WriteString("\x1b[16t")
-	tty.Sync()
-
-	// Read response with timeout
-	resultChan := make(chan string, 1)
-	go func() {
-		buf := make([]byte, 32)
-		n, _ := tty.Read(buf)
-		if n > 0 {
-			resultChan <- string(buf[:n])
-		} else {
-			resultChan <- ""
-		}
-	}()
-
-	select {
-	case response := <-resultChan:
-		if response == "" {
-			return 0, 0
-		}
-		// Parse response: ESC [ 6 ; height ; width t
-		var cellHeight, cellWidth int
-		if _, err := fmt.Sscanf(response, "\x1b[6;%d;%dt", &cellHeight, &cellWidth); err == nil {
-			if cellWidth > 0 && cellHeight > 0 {
-				return float64(cellWidth), float64(cellHeight)
-			}
-		}
-	case <-time.After(100 * time.Millisecond):
-		// Timeout - terminal didn't respond
-	}
-
-	return 0, 0
+// waitForInput reports whether fd has input to read within timeout.
+func waitForInput(fd int, timeout time.Duration) bool {
+	fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+	n, err := unix.Poll(fds, int(timeout.Milliseconds()))
+	return err == nil && n > 0
 }
 
 func (d *DocumentViewer) setRawMode() (*term.State, error) {
@@ -244,59 +316,3 @@ func (d *DocumentViewer) restoreTerminal(old *term.State) {
 		term.Restore(int(os.Stdin.Fd()), old)
 	}
 }
-
-func (d *DocumentViewer) readSingleChar() byte {
-	buf := make([]byte, 1)
-	n, _ := os.Stdin.Read(buf)
-	if n == 0 {
-		return 0
-	}
-
-	// Handle escape sequences (arrow keys, shift+arrow)
-	// Read bytes one at a time to avoid partial reads dropping sequence bytes
-	if buf[0] == 27 {
-		b := make([]byte, 1)
-		n, _ = os.Stdin.Read(b)
-		if n == 1 && b[0] == '[' {
-			n, _ = os.Stdin.Read(b)
-			if n == 1 {
-				switch b[0] {
-				case 'A': // Up arrow -> previous page (like k)
-					return 'k'
-				case 'B': // Down arrow -> next page (like j)
-					return 'j'
-				case 'C': // Right arrow -> next page
-					return 'j'
-				case 'D': // Left arrow -> previous page
-					return 'k'
-				case '1':
-					// Could be shift+arrow: ESC [ 1 ; 2 A/B/C/D
-					seq := make([]byte, 3)
-					n2 := 0
-					for i := 0; i < 3; i++ {
-						nn, _ := os.Stdin.Read(seq[i : i+1])
-						if nn == 0 {
-							break
-						}
-						n2++
-					}
-					if n2 == 3 && seq[0] == ';' && seq[1] == '2' {
-						switch seq[2] {
-						case 'A': // Shift+Up
-							return 'K' // uppercase = shift
-						case 'B': // Shift+Down
-							return 'J' // uppercase = shift
-						case 'C': // Shift+Right
-							return 'J' // uppercase = shift (forward)
-						case 'D': // Shift+Left
-							return 'K' // uppercase = shift (backward)
-						}
-					}
-				}
-			}
-		}
-		return 27 // Plain ESC
-	}
-
-	return buf[0]
-}